@@ -0,0 +1,151 @@
+/*Copyright (c) 2019 Cisco and/or its affiliates.
+
+This software is licensed to you under the terms of the Cisco Sample
+Code License, Version 1.0 (the "License"). You may obtain a copy of the
+License at
+
+https://developer.cisco.com/docs/licenses
+
+All use of the material herein must be in accordance with the terms of
+the License. All rights not expressly granted by the License are
+reserved. Unless required by applicable law or agreed to separately in
+writing, software distributed under the License is distributed on an "AS
+IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+or implied.*/
+
+package main
+
+// Calls to the CCP v3 API that aren't available in the client library. They go through the
+// client's HTTPClient so they share the session, TLS and retry handling set up in Config.Client.
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ccp-client-library/ccp"
+)
+
+// apiError is returned for any response from CCP outside of the 2xx range
+type apiError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *apiError) Error() string {
+	return strconv.Itoa(e.StatusCode) + " " + http.StatusText(e.StatusCode) + ": " + e.Body
+}
+
+func doRequest(ctx context.Context, client *ccp.Client, method string, path string, in interface{}, out interface{}) error {
+
+	var body []byte
+
+	if in != nil {
+		j, err := json.Marshal(in)
+
+		if err != nil {
+			return err
+		}
+
+		body = j
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(client.BaseURL, "/")+path, bytes.NewReader(body))
+
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.HTTPClient.Do(req)
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &apiError{StatusCode: resp.StatusCode, Body: string(respBody)}
+	}
+
+	if out == nil || len(respBody) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(respBody, out)
+}
+
+func getCluster(client *ccp.Client, uuid string) (*ccp.Cluster, error) {
+
+	var cluster ccp.Cluster
+
+	if err := doRequest(context.Background(), client, http.MethodGet, "/v3/clusters/"+uuid+"/", nil, &cluster); err != nil {
+		return nil, err
+	}
+
+	return &cluster, nil
+}
+
+func deleteCluster(ctx context.Context, client *ccp.Client, uuid string) error {
+
+	return doRequest(ctx, client, http.MethodDelete, "/v3/clusters/"+uuid+"/", nil, nil)
+}
+
+func getACIProfile(client *ccp.Client, uuid string) (*ccp.ACIProfile, error) {
+
+	var aciProfile ccp.ACIProfile
+
+	if err := doRequest(context.Background(), client, http.MethodGet, "/v3/aci-profiles/"+uuid+"/", nil, &aciProfile); err != nil {
+		return nil, err
+	}
+
+	return &aciProfile, nil
+}
+
+func deleteACIProfile(client *ccp.Client, uuid string) error {
+
+	return doRequest(context.Background(), client, http.MethodDelete, "/v3/aci-profiles/"+uuid+"/", nil, nil)
+}
+
+func getUser(client *ccp.Client, username string) (*ccp.User, error) {
+
+	var user ccp.User
+
+	if err := doRequest(context.Background(), client, http.MethodGet, "/v3/users/"+username+"/", nil, &user); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func deleteUser(client *ccp.Client, username string) error {
+
+	return doRequest(context.Background(), client, http.MethodDelete, "/v3/users/"+username+"/", nil, nil)
+}
+
+func addNodePool(client *ccp.Client, clusterUUID string, pool *ccp.WorkerNodePool) error {
+
+	return doRequest(context.Background(), client, http.MethodPost, "/v3/clusters/"+clusterUUID+"/node-pools/", pool, nil)
+}
+
+func deleteNodePool(ctx context.Context, client *ccp.Client, clusterUUID string, name string) error {
+
+	return doRequest(ctx, client, http.MethodDelete, "/v3/clusters/"+clusterUUID+"/node-pools/"+name+"/", nil, nil)
+}
+
+func patchNodePool(client *ccp.Client, clusterUUID string, name string, pool *ccp.WorkerNodePool) error {
+
+	return doRequest(context.Background(), client, http.MethodPatch, "/v3/clusters/"+clusterUUID+"/node-pools/"+name+"/", pool, nil)
+}
@@ -16,7 +16,16 @@ or implied.*/
 package main
 
 import (
-	"fmt"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strconv"
+	"strings"
 
 	"github.com/ccp-client-library/ccp"
 )
@@ -25,17 +34,130 @@ type Config struct {
 	Username string
 	Password string
 	Base_url string
+
+	Ca_file          string
+	Ca_pem           string
+	Client_cert_file string
+	Client_key_file  string
+	Insecure         bool
+
+	Max_retries    int
+	Retry_wait_min int
+	Retry_wait_max int
 }
 
-func (c *Config) Client() *ccp.Client {
+func (c *Config) Client() (*ccp.Client, error) {
+
+	if c.Retry_wait_max < c.Retry_wait_min {
+		return nil, errors.New("RETRY_WAIT_MAX (" + strconv.Itoa(c.Retry_wait_max) + ") MUST BE AT LEAST RETRY_WAIT_MIN (" + strconv.Itoa(c.Retry_wait_min) + ")")
+	}
+
+	if _, err := url.ParseRequestURI(c.Base_url); err != nil {
+		return nil, errors.New("INVALID BASE URL " + c.Base_url + ": " + err.Error())
+	}
+
+	tlsConfig, err := c.tlsConfig()
+
+	if err != nil {
+		return nil, err
+	}
+
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	base.TLSClientConfig = tlsConfig
 
 	client := ccp.NewClient(c.Username, c.Password, c.Base_url)
+	client.HTTPClient = newHTTPClient(base)
 
-	err := client.Login(client)
+	err = client.Login(client)
 
 	if err != nil {
-		fmt.Println(err)
+		return nil, c.loginError(err)
+	}
+
+	// sessions expire during long applies so requests go through a transport that logs in again when needed
+	client.HTTPClient.Transport = newTransport(c, base, client.HTTPClient.Jar)
+
+	return client, nil
+}
+
+func newHTTPClient(base http.RoundTripper) *http.Client {
+
+	jar, _ := cookiejar.New(nil)
+
+	return &http.Client{
+		Transport: base,
+		Jar:       jar,
+	}
+}
+
+// tlsConfig builds the TLS settings used to talk to CCP from the CA and client certificate arguments
+func (c *Config) tlsConfig() (*tls.Config, error) {
+
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: c.Insecure,
+	}
+
+	caPEM := []byte(c.Ca_pem)
+
+	if c.Ca_file != "" {
+		contents, err := ioutil.ReadFile(c.Ca_file)
+
+		if err != nil {
+			return nil, errors.New("UNABLE TO READ CA FILE " + c.Ca_file + ": " + err.Error())
+		}
+
+		caPEM = contents
+	}
+
+	if len(caPEM) > 0 {
+		pool := x509.NewCertPool()
+
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, errors.New("NO VALID PEM CERTIFICATES FOUND IN CA BUNDLE")
+		}
+
+		tlsConfig.RootCAs = pool
+	}
+
+	if c.Client_cert_file != "" || c.Client_key_file != "" {
+		if c.Client_cert_file == "" || c.Client_key_file == "" {
+			return nil, errors.New("CLIENT_CERT_FILE AND CLIENT_KEY_FILE MUST BE SET TOGETHER")
+		}
+
+		cert, err := tls.LoadX509KeyPair(c.Client_cert_file, c.Client_key_file)
+
+		if err != nil {
+			return nil, errors.New("UNABLE TO LOAD CLIENT CERTIFICATE: " + err.Error())
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// loginError works out why logging in to CCP failed so the user is told whether to check their
+// credentials, the base_url or the certificate presented by CCP
+func (c *Config) loginError(err error) error {
+
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostname x509.HostnameError
+	var invalidCert x509.CertificateInvalidError
+	var netErr net.Error
+
+	msg := strings.ToLower(err.Error())
+
+	switch {
+	case errors.As(err, &unknownAuthority), errors.As(err, &hostname), errors.As(err, &invalidCert),
+		strings.Contains(msg, "x509"), strings.Contains(msg, "tls"), strings.Contains(msg, "certificate"):
+		return errors.New("TLS ERROR CONNECTING TO CCP AT " + c.Base_url + ": " + err.Error())
+	case errors.As(err, &netErr), strings.Contains(msg, "connection refused"), strings.Contains(msg, "no such host"),
+		strings.Contains(msg, "timeout"), strings.Contains(msg, "unreachable"):
+		return errors.New("UNABLE TO REACH CCP AT " + c.Base_url + ": " + err.Error())
+	case strings.Contains(msg, "401"), strings.Contains(msg, "403"), strings.Contains(msg, "unauthorized"),
+		strings.Contains(msg, "forbidden"), strings.Contains(msg, "invalid"), strings.Contains(msg, "credentials"):
+		return errors.New("AUTHENTICATION FAILED FOR CCP USER " + c.Username + " AT " + c.Base_url + ": " + err.Error())
 	}
 
-	return client
+	return errors.New("UNABLE TO LOGIN TO CCP AT " + c.Base_url + ": " + err.Error())
 }
@@ -17,6 +17,7 @@ package main
 
 import (
 	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/hashicorp/terraform/helper/validation"
 )
 
 func Provider() *schema.Provider {
@@ -40,11 +41,65 @@ func Provider() *schema.Provider {
 				DefaultFunc: schema.EnvDefaultFunc("CCP_URL", nil),
 				Description: "URL to the Cisco Container Platform",
 			},
+			"ca_file": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				DefaultFunc:   schema.EnvDefaultFunc("CCP_CA_FILE", nil),
+				ConflictsWith: []string{"ca_pem"},
+				Description:   "Path to a PEM encoded CA bundle used to verify the Cisco Container Platform certificate",
+			},
+			"ca_pem": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				DefaultFunc:   schema.EnvDefaultFunc("CCP_CA_PEM", nil),
+				ConflictsWith: []string{"ca_file"},
+				Description:   "PEM encoded CA bundle used to verify the Cisco Container Platform certificate",
+			},
+			"client_cert_file": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("CCP_CLIENT_CERT_FILE", nil),
+				Description: "Path to a PEM encoded client certificate presented to the Cisco Container Platform",
+			},
+			"client_key_file": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("CCP_CLIENT_KEY_FILE", nil),
+				Description: "Path to the PEM encoded private key for client_cert_file",
+			},
+			"insecure": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("CCP_INSECURE", false),
+				Description: "Skip verification of the Cisco Container Platform certificate",
+			},
+			"max_retries": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				DefaultFunc:  schema.EnvDefaultFunc("CCP_MAX_RETRIES", 3),
+				ValidateFunc: validation.IntAtLeast(0),
+				Description:  "Number of times a GET or DELETE is retried when the Cisco Container Platform is temporarily unavailable",
+			},
+			"retry_wait_min": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				DefaultFunc:  schema.EnvDefaultFunc("CCP_RETRY_WAIT_MIN", 1),
+				ValidateFunc: validation.IntAtLeast(1),
+				Description:  "Minimum number of seconds to wait before retrying a request",
+			},
+			"retry_wait_max": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				DefaultFunc:  schema.EnvDefaultFunc("CCP_RETRY_WAIT_MAX", 30),
+				ValidateFunc: validation.IntAtLeast(1),
+				Description:  "Maximum number of seconds to wait before retrying a request",
+			},
 		},
 		ResourcesMap: map[string]*schema.Resource{
 			"ccp_user":        resourceUser(),
 			"ccp_cluster":     resourceCluster(),
 			"ccp_aci_profile": resourceACIProfile(),
+			"ccp_node_pool":   resourceNodePool(),
 		},
 		ConfigureFunc: providerConfigure,
 	}
@@ -55,7 +110,17 @@ func providerConfigure(d *schema.ResourceData) (interface{}, error) {
 		Username: d.Get("username").(string),
 		Password: d.Get("password").(string),
 		Base_url: d.Get("base_url").(string),
+
+		Ca_file:          d.Get("ca_file").(string),
+		Ca_pem:           d.Get("ca_pem").(string),
+		Client_cert_file: d.Get("client_cert_file").(string),
+		Client_key_file:  d.Get("client_key_file").(string),
+		Insecure:         d.Get("insecure").(bool),
+
+		Max_retries:    d.Get("max_retries").(int),
+		Retry_wait_min: d.Get("retry_wait_min").(int),
+		Retry_wait_max: d.Get("retry_wait_max").(int),
 	}
 
-	return config.Client(), nil
+	return config.Client()
 }
@@ -17,9 +17,13 @@ package main
 
 import (
 	"errors"
+	"log"
+	"strconv"
+	"strings"
 
 	"github.com/ccp-client-library/ccp"
 	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/hashicorp/terraform/helper/validation"
 )
 
 func resourceACIProfile() *schema.Resource {
@@ -29,6 +33,12 @@ func resourceACIProfile() *schema.Resource {
 		Update: resourceACIProfileUpdate,
 		Delete: resourceACIProfileDelete,
 
+		Importer: &schema.ResourceImporter{
+			State: resourceACIProfileImport,
+		},
+
+		CustomizeDiff: resourceACIProfileCustomizeDiff,
+
 		Schema: map[string]*schema.Schema{
 
 			"uuid": &schema.Schema{
@@ -47,18 +57,21 @@ func resourceACIProfile() *schema.Resource {
 				Type:     schema.TypeString,
 				Required: true,
 			},
+			// only a hash of the password is kept in state, see hashSecret
 			"apic_password": &schema.Schema{
 				Type:      schema.TypeString,
 				Required:  true,
 				Sensitive: true,
+				StateFunc: hashSecret,
 			},
 			"aci_vmm_domain_name": &schema.Schema{
 				Type:     schema.TypeString,
 				Required: true,
 			},
 			"aci_infra_vlan_id": &schema.Schema{
-				Type:     schema.TypeInt,
-				Required: true,
+				Type:         schema.TypeInt,
+				Required:     true,
+				ValidateFunc: validation.IntBetween(1, 4094),
 			},
 			"vrf_name": &schema.Schema{
 				Type:     schema.TypeString,
@@ -86,24 +99,29 @@ func resourceACIProfile() *schema.Resource {
 				Required: true,
 			},
 			"node_vlan_start": &schema.Schema{
-				Type:     schema.TypeInt,
-				Required: true,
+				Type:         schema.TypeInt,
+				Required:     true,
+				ValidateFunc: validation.IntBetween(1, 4094),
 			},
 			"node_vlan_end": &schema.Schema{
-				Type:     schema.TypeInt,
-				Required: true,
+				Type:         schema.TypeInt,
+				Required:     true,
+				ValidateFunc: validation.IntBetween(1, 4094),
 			},
 			"pod_subnet_start": &schema.Schema{
-				Type:     schema.TypeString,
-				Required: true,
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validateGatewayCIDR,
 			},
 			"service_subnet_start": &schema.Schema{
-				Type:     schema.TypeString,
-				Required: true,
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validateGatewayCIDR,
 			},
 			"multicast_range": &schema.Schema{
-				Type:     schema.TypeString,
-				Required: true,
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validateMulticastCIDR,
 			},
 			"aci_tenant": &schema.Schema{
 				Type:     schema.TypeString,
@@ -161,10 +179,15 @@ func resourceACIProfileRead(d *schema.ResourceData, m interface{}) error {
 
 	client := m.(*ccp.Client)
 
-	aciProfile, err := client.GetACIProfileByName(d.Get("name").(string))
+	aciProfile, err := getACIProfile(client, d.Id())
 
 	if err != nil {
-		return errors.New("UNABLE TO RETRIEVE DETAILS FOR ACI PROFILE: " + d.Get("name").(string))
+		if isNotFound(err) {
+			log.Printf("[WARN] ACI profile %s no longer exists in CCP, removing from state", d.Id())
+			d.SetId("")
+			return nil
+		}
+		return errors.New("UNABLE TO RETRIEVE DETAILS FOR ACI PROFILE: " + d.Get("name").(string) + ": " + err.Error())
 	}
 
 	return setACIProfileResourceData(d, aciProfile)
@@ -185,7 +208,6 @@ func resourceACIProfileUpdate(d *schema.ResourceData, m interface{}) error {
 		Name:                     ccp.String(d.Get("name").(string)),
 		APICHosts:                ccp.String(d.Get("apic_hosts").(string)),
 		APICUsername:             ccp.String(d.Get("apic_username").(string)),
-		APICPassword:             ccp.String(d.Get("apic_password").(string)),
 		ACIVMMDomainName:         ccp.String(d.Get("aci_vmm_domain_name").(string)),
 		ACIInfraVLANID:           ccp.Int(d.Get("aci_infra_vlan_id").(int)),
 		VRFName:                  ccp.String(d.Get("vrf_name").(string)),
@@ -202,16 +224,21 @@ func resourceACIProfileUpdate(d *schema.ResourceData, m interface{}) error {
 		ACITenant:                ccp.String(d.Get("aci_tenant").(string)),
 	}
 
-	profile, err := client.PatchACIProfile(&newACIProfile, d.Get("uuid").(string))
+	// the password in state is only a hash so it is sent only when it has been changed
+	if d.HasChange("apic_password") {
+		newACIProfile.APICPassword = ccp.String(d.Get("apic_password").(string))
+	}
+
+	profile, err := client.PatchACIProfile(&newACIProfile, d.Id())
 
 	if err != nil {
 		return errors.New(err.Error())
 	}
 
-	profile, err = client.GetACIProfileByName(d.Get("name").(string))
+	profile, err = getACIProfile(client, d.Id())
 
 	if err != nil {
-		return errors.New("UNABLE TO RETRIEVE DETAILS FOR ACI PROFILE: " + d.Get("name").(string))
+		return errors.New("UNABLE TO RETRIEVE DETAILS FOR ACI PROFILE: " + d.Get("name").(string) + ": " + err.Error())
 	}
 
 	return setACIProfileResourceData(d, profile)
@@ -222,9 +249,9 @@ func resourceACIProfileDelete(d *schema.ResourceData, m interface{}) error {
 
 	client := m.(*ccp.Client)
 
-	err := client.DeleteACIProfile(d.Get("uuid").(string))
+	err := deleteACIProfile(client, d.Id())
 
-	if err != nil {
+	if err != nil && !isNotFound(err) {
 		return errors.New(err.Error())
 	}
 
@@ -232,8 +259,61 @@ func resourceACIProfileDelete(d *schema.ResourceData, m interface{}) error {
 	return nil
 }
 
+// resourceACIProfileCustomizeDiff checks the VLAN range and that the pod and service subnets don't overlap
+func resourceACIProfileCustomizeDiff(d *schema.ResourceDiff, m interface{}) error {
+
+	if d.NewValueKnown("node_vlan_start") && d.NewValueKnown("node_vlan_end") {
+		start := d.Get("node_vlan_start").(int)
+		end := d.Get("node_vlan_end").(int)
+
+		if start >= end {
+			return errors.New("node_vlan_start: MUST BE LOWER THAN node_vlan_end, GOT " + strconv.Itoa(start) + " AND " + strconv.Itoa(end))
+		}
+	}
+
+	if d.NewValueKnown("pod_subnet_start") && d.NewValueKnown("service_subnet_start") {
+		pod := d.Get("pod_subnet_start").(string)
+		service := d.Get("service_subnet_start").(string)
+
+		if cidrsOverlap(pod, service) {
+			return errors.New("pod_subnet_start: " + pod + " OVERLAPS WITH service_subnet_start " + service)
+		}
+	}
+
+	return nil
+}
+
+// resourceACIProfileImport accepts either the UUID of the ACI profile or name:<profile name>
+func resourceACIProfileImport(d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+
+	client := m.(*ccp.Client)
+
+	var aciProfile *ccp.ACIProfile
+	var err error
+
+	if strings.HasPrefix(d.Id(), "name:") {
+		aciProfile, err = client.GetACIProfileByName(strings.TrimPrefix(d.Id(), "name:"))
+	} else {
+		aciProfile, err = getACIProfile(client, d.Id())
+	}
+
+	if err != nil {
+		return nil, errors.New("UNABLE TO IMPORT ACI PROFILE " + d.Id() + ": " + err.Error())
+	}
+
+	d.SetId(*aciProfile.UUID)
+
+	if err := setACIProfileResourceData(d, aciProfile); err != nil {
+		return nil, err
+	}
+
+	return []*schema.ResourceData{d}, nil
+}
+
 func setACIProfileResourceData(d *schema.ResourceData, u *ccp.ACIProfile) error {
 
+	// the APIC password is write only, CCP doesn't return it so the hash in state is left as it is
+
 	if err := d.Set("uuid", u.UUID); err != nil {
 		return errors.New("CANNOT SET UUID")
 	}
@@ -246,9 +326,6 @@ func setACIProfileResourceData(d *schema.ResourceData, u *ccp.ACIProfile) error
 	if err := d.Set("apic_username", u.APICUsername); err != nil {
 		return errors.New("CANNOT SET APIC USERNAME")
 	}
-	if err := d.Set("apic_password", u.APICPassword); err != nil {
-		return errors.New("CANNOT SET APIC PASSWORD")
-	}
 	if err := d.Set("aci_vmm_domain_name", u.ACIVMMDomainName); err != nil {
 		return errors.New("CANNOT SET ACI VMM DOMAIN NAME")
 	}
@@ -16,10 +16,19 @@ or implied.*/
 package main
 
 import (
+	"context"
 	"errors"
+	"log"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/ccp-client-library/ccp"
+	"github.com/hashicorp/terraform/helper/customdiff"
 	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/hashicorp/terraform/helper/validation"
 )
 
 func resourceCluster() *schema.Resource {
@@ -29,50 +38,77 @@ func resourceCluster() *schema.Resource {
 		Update: resourceClusterUpdate,
 		Delete: resourceClusterDelete,
 
+		Importer: &schema.ResourceImporter{
+			State: resourceClusterImport,
+		},
+
+		CustomizeDiff: customdiff.All(
+			validateClusterNetwork,
+			validateClusterNodePools,
+			validateClusterUpgrade,
+			validateWorkerNodePoolChanges,
+		),
+
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(60 * time.Minute),
+			Update: schema.DefaultTimeout(60 * time.Minute),
+			Delete: schema.DefaultTimeout(30 * time.Minute),
+		},
+
 		Schema: map[string]*schema.Schema{
 			"uuid": &schema.Schema{
 				Type:     schema.TypeString,
 				Computed: true,
 			},
 			"type": &schema.Schema{
-				Type:     schema.TypeString,
-				Required: true,
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.StringInSlice(clusterTypes, false),
 			},
 			"name": &schema.Schema{
 				Type:     schema.TypeString,
 				Required: true,
+				ForceNew: true,
 			},
 			"provider_client_config_uuid": &schema.Schema{
 				Type:     schema.TypeString,
 				Required: true,
+				ForceNew: true,
 			},
 			"status": &schema.Schema{
 				Type:     schema.TypeString,
 				Optional: true,
 			},
 			"kubernetes_version": &schema.Schema{
-				Type:     schema.TypeString,
-				Required: true,
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validateKubernetesVersion,
 			},
 			"kube_config": &schema.Schema{
-				Type:     schema.TypeString,
-				Optional: true,
+				Type:      schema.TypeString,
+				Computed:  true,
+				Sensitive: true,
 			},
 			"ip_allocation_method": &schema.Schema{
-				Type:     schema.TypeString,
-				Required: true,
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.StringInSlice(ipAllocationMethods, false),
 			},
 			"master_vip": &schema.Schema{
 				Type:     schema.TypeString,
 				Computed: true,
 			},
 			"loadbalancer_ip_num": &schema.Schema{
-				Type:     schema.TypeInt,
-				Optional: true,
+				Type:         schema.TypeInt,
+				Optional:     true,
+				ValidateFunc: validation.IntAtLeast(0),
 			},
 			"subnet_uuid": &schema.Schema{
 				Type:     schema.TypeString,
 				Optional: true,
+				ForceNew: true,
 			},
 			"ntp_pools": {
 				Type:     schema.TypeList,
@@ -115,33 +151,41 @@ func resourceCluster() *schema.Resource {
 				Optional: true,
 			},
 			"docker_bip": &schema.Schema{
-				Type:     schema.TypeString,
-				Optional: true,
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				ValidateFunc: validateCIDR,
 			},
 			"infra": &schema.Schema{
 				Type:     schema.TypeList,
 				Required: true,
+				ForceNew: true,
 				Elem: &schema.Resource{
 					Schema: map[string]*schema.Schema{
 						"datacenter": &schema.Schema{
 							Type:     schema.TypeString,
 							Required: true,
+							ForceNew: true,
 						},
 						"cluster": &schema.Schema{
 							Type:     schema.TypeString,
 							Required: true,
+							ForceNew: true,
 						},
 						"datastore": &schema.Schema{
 							Type:     schema.TypeString,
 							Required: true,
+							ForceNew: true,
 						},
 						"resource_pool": &schema.Schema{
 							Type:     schema.TypeString,
 							Required: true,
+							ForceNew: true,
 						},
 						"networks": &schema.Schema{
 							Type:     schema.TypeList,
 							Optional: true,
+							ForceNew: true,
 							Elem:     &schema.Schema{Type: schema.TypeString},
 						},
 					},
@@ -155,35 +199,47 @@ func resourceCluster() *schema.Resource {
 						"name": &schema.Schema{
 							Type:     schema.TypeString,
 							Required: true,
+							ForceNew: true,
 						},
 						"size": &schema.Schema{
-							Type:     schema.TypeInt,
-							Required: true,
+							Type:         schema.TypeInt,
+							Required:     true,
+							ForceNew:     true,
+							ValidateFunc: validation.IntAtLeast(1),
 						},
 						"template": &schema.Schema{
 							Type:     schema.TypeString,
 							Required: true,
 						},
 						"vcpus": &schema.Schema{
-							Type:     schema.TypeInt,
-							Required: true,
+							Type:         schema.TypeInt,
+							Required:     true,
+							ForceNew:     true,
+							ValidateFunc: validation.IntAtLeast(1),
 						},
 						"memory": &schema.Schema{
-							Type:     schema.TypeInt,
-							Required: true,
+							Type:         schema.TypeInt,
+							Required:     true,
+							ForceNew:     true,
+							ValidateFunc: validation.IntAtLeast(1024),
 						},
 						"gpus": &schema.Schema{
 							Type:     schema.TypeList,
 							Required: true,
+							ForceNew: true,
 							Elem:     &schema.Schema{Type: schema.TypeString},
 						},
 						"ssh_user": &schema.Schema{
 							Type:     schema.TypeString,
 							Required: true,
+							ForceNew: true,
 						},
 						"ssh_key": &schema.Schema{
-							Type:     schema.TypeString,
-							Required: true,
+							Type:         schema.TypeString,
+							Required:     true,
+							ForceNew:     true,
+							Sensitive:    true,
+							ValidateFunc: validateSSHKey,
 						},
 						"nodes": &schema.Schema{
 							Type:     schema.TypeList,
@@ -222,8 +278,9 @@ func resourceCluster() *schema.Resource {
 							},
 						},
 						"kubernetes_version": &schema.Schema{
-							Type:     schema.TypeString,
-							Required: true,
+							Type:         schema.TypeString,
+							Required:     true,
+							ValidateFunc: validateKubernetesVersion,
 						},
 					},
 				},
@@ -238,20 +295,23 @@ func resourceCluster() *schema.Resource {
 							Required: true,
 						},
 						"size": &schema.Schema{
-							Type:     schema.TypeInt,
-							Required: true,
+							Type:         schema.TypeInt,
+							Required:     true,
+							ValidateFunc: validation.IntAtLeast(0),
 						},
 						"template": &schema.Schema{
 							Type:     schema.TypeString,
 							Required: true,
 						},
 						"vcpus": &schema.Schema{
-							Type:     schema.TypeInt,
-							Required: true,
+							Type:         schema.TypeInt,
+							Required:     true,
+							ValidateFunc: validation.IntAtLeast(1),
 						},
 						"memory": &schema.Schema{
-							Type:     schema.TypeInt,
-							Required: true,
+							Type:         schema.TypeInt,
+							Required:     true,
+							ValidateFunc: validation.IntAtLeast(1024),
 						},
 						"gpus": &schema.Schema{
 							Type:     schema.TypeList,
@@ -263,8 +323,10 @@ func resourceCluster() *schema.Resource {
 							Required: true,
 						},
 						"ssh_key": &schema.Schema{
-							Type:     schema.TypeString,
-							Required: true,
+							Type:         schema.TypeString,
+							Required:     true,
+							Sensitive:    true,
+							ValidateFunc: validateSSHKey,
 						},
 						"nodes": &schema.Schema{
 							Type:     schema.TypeList,
@@ -273,38 +335,39 @@ func resourceCluster() *schema.Resource {
 								Schema: map[string]*schema.Schema{
 									"name": &schema.Schema{
 										Type:     schema.TypeString,
-										Required: true,
+										Computed: true,
 									},
 									"status": &schema.Schema{
 										Type:     schema.TypeString,
-										Required: true,
+										Computed: true,
 									},
 									"status_detail": &schema.Schema{
 										Type:     schema.TypeString,
-										Required: true,
+										Computed: true,
 									},
 									"status_reason": &schema.Schema{
 										Type:     schema.TypeString,
-										Required: true,
+										Computed: true,
 									},
 									"public_ip": &schema.Schema{
 										Type:     schema.TypeString,
-										Required: true,
+										Computed: true,
 									},
 									"private_ip": &schema.Schema{
 										Type:     schema.TypeString,
-										Required: true,
+										Computed: true,
 									},
 									"phase": &schema.Schema{
 										Type:     schema.TypeString,
-										Required: true,
+										Computed: true,
 									},
 								},
 							},
 						},
 						"kubernetes_version": &schema.Schema{
-							Type:     schema.TypeString,
-							Required: true,
+							Type:         schema.TypeString,
+							Required:     true,
+							ValidateFunc: validateKubernetesVersion,
 						},
 					},
 				},
@@ -312,20 +375,26 @@ func resourceCluster() *schema.Resource {
 			"network_plugin": &schema.Schema{
 				Type:     schema.TypeList,
 				Required: true,
+				ForceNew: true,
 				Elem: &schema.Resource{
 					Schema: map[string]*schema.Schema{
 						"name": &schema.Schema{
-							Type:     schema.TypeString,
-							Required: true,
+							Type:         schema.TypeString,
+							Required:     true,
+							ForceNew:     true,
+							ValidateFunc: validation.StringInSlice(networkPlugins, false),
 						},
 						"details": &schema.Schema{
 							Type:     schema.TypeList,
 							Required: true,
+							ForceNew: true,
 							Elem: &schema.Resource{
 								Schema: map[string]*schema.Schema{
 									"pod_cidr": &schema.Schema{
-										Type:     schema.TypeString,
-										Optional: true,
+										Type:         schema.TypeString,
+										Optional:     true,
+										ForceNew:     true,
+										ValidateFunc: validateCIDR,
 									},
 								},
 							},
@@ -336,18 +405,22 @@ func resourceCluster() *schema.Resource {
 			"ingress_as_lb": &schema.Schema{
 				Type:     schema.TypeBool,
 				Optional: true,
+				ForceNew: true,
 			},
 			"nginx_ingress_class": &schema.Schema{
 				Type:     schema.TypeString,
 				Optional: true,
+				ForceNew: true,
 			},
 			"etcd_encrypted": &schema.Schema{
 				Type:     schema.TypeBool,
 				Optional: true,
+				ForceNew: true,
 			},
 			"skip_management": &schema.Schema{
 				Type:     schema.TypeBool,
 				Optional: true,
+				ForceNew: true,
 			},
 			"docker_no_proxy": {
 				Type:     schema.TypeList,
@@ -355,16 +428,20 @@ func resourceCluster() *schema.Resource {
 				Elem:     &schema.Schema{Type: schema.TypeString},
 			},
 			"routable_cidr": &schema.Schema{
-				Type:     schema.TypeString,
-				Optional: true,
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				ValidateFunc: validateCIDR,
 			},
 			"image_prefix": &schema.Schema{
 				Type:     schema.TypeString,
 				Optional: true,
+				ForceNew: true,
 			},
 			"aci_profile_uuid": &schema.Schema{
 				Type:     schema.TypeString,
 				Optional: true,
+				ForceNew: true,
 			},
 			"description": &schema.Schema{
 				Type:     schema.TypeString,
@@ -373,6 +450,7 @@ func resourceCluster() *schema.Resource {
 			"aws_iam_enabled": &schema.Schema{
 				Type:     schema.TypeBool,
 				Optional: true,
+				ForceNew: true,
 			},
 		},
 	}
@@ -508,55 +586,7 @@ func resourceClusterCreate(d *schema.ResourceData, m interface{}) error {
 	workerNodePools := d.Get("worker_node_pools").([]interface{})
 
 	for _, workerNode := range workerNodePools {
-
-		worker := workerNode.(map[string]interface{})
-
-		gpuKeys := worker["gpus"].([]interface{})
-
-		var gpus []string
-
-		for _, gpu := range gpuKeys {
-			gpus = append(gpus, gpu.(string))
-		}
-
-		var nodePool []ccp.Node
-		nodeKeys := worker["nodes"].([]interface{})
-
-		for _, node := range nodeKeys {
-
-			tmpNode := node.(map[string]interface{})
-
-			nodes := ccp.Node{
-				Name:         ccp.String(tmpNode["name"].(string)),
-				Status:       ccp.String(tmpNode["status"].(string)),
-				StatusDetail: ccp.String(tmpNode["status_detail"].(string)),
-				StatusReason: ccp.String(tmpNode["status_reason"].(string)),
-				PublicIP:     ccp.String(tmpNode["public_ip"].(string)),
-				PrivateIP:    ccp.String(tmpNode["private_ip"].(string)),
-				Phase:        ccp.String(tmpNode["phase"].(string)),
-			}
-
-			nodePool = append(nodePool, nodes)
-		}
-
-		if len(nodePool) == 0 {
-			nodePool = nil
-		}
-
-		workerNodePool := ccp.WorkerNodePool{
-			Name:     ccp.String(worker["name"].(string)),
-			Size:     ccp.Int64(int64(worker["size"].(int))),
-			Template: ccp.String(worker["template"].(string)),
-			VCPUs:    ccp.Int64(int64(worker["vcpus"].(int))),
-			Memory:   ccp.Int64(int64(worker["memory"].(int))),
-			//GPUs:              &gpus,
-			SSHUser:           ccp.String(worker["ssh_user"].(string)),
-			SSHKey:            ccp.String(worker["ssh_key"].(string)),
-			Nodes:             &nodePool,
-			KubernetesVersion: ccp.String(worker["kubernetes_version"].(string)),
-		}
-
-		workerPool = append(workerPool, workerNodePool)
+		workerPool = append(workerPool, expandWorkerNodePool(workerNode.(map[string]interface{})))
 	}
 
 	dockerNoProxy := []string{}
@@ -605,7 +635,6 @@ func resourceClusterCreate(d *schema.ResourceData, m interface{}) error {
 		InfraProviderUUID:  ccp.String(d.Get("provider_client_config_uuid").(string)),
 		Status:             ccp.String(d.Get("status").(string)),
 		KubernetesVersion:  ccp.String(d.Get("kubernetes_version").(string)),
-		KubeConfig:         ccp.String(d.Get("kube_config").(string)),
 		IPAllocationMethod: ccp.String(d.Get("ip_allocation_method").(string)),
 		MasterVIP:          ccp.String(d.Get("master_vip").(string)),
 		LoadBalancerIPNum:  ccp.Int64(int64(d.Get("loadbalancer_ip_num").(int))),
@@ -634,20 +663,23 @@ func resourceClusterCreate(d *schema.ResourceData, m interface{}) error {
 		AWSIamEnabled:      aws_iam_enabled,
 	}
 
-	cluster, err := client.AddClusterSynchronous(&newCluster)
+	cluster, err := client.AddCluster(&newCluster)
 
 	if err != nil {
 		return errors.New(err.Error())
 	}
 
-	uuid := *cluster.UUID
+	if cluster == nil || cluster.UUID == nil {
+		return malformedClusterError("uuid")
+	}
 
-	d.SetId(uuid)
+	// record the cluster straight away so it is tainted rather than orphaned if anything goes wrong from here on
+	d.SetId(*cluster.UUID)
 
-	cluster, err = client.GetClusterByName(d.Get("name").(string))
+	cluster, err = waitForCluster(client, d.Id(), d.Timeout(schema.TimeoutCreate))
 
 	if err != nil {
-		return errors.New(err.Error())
+		return errors.New("ERROR CREATING CLUSTER " + d.Get("name").(string) + ": " + err.Error())
 	}
 
 	return setClusterResourceData(d, cluster)
@@ -657,10 +689,15 @@ func resourceClusterRead(d *schema.ResourceData, m interface{}) error {
 
 	client := m.(*ccp.Client)
 
-	cluster, err := client.GetClusterByName(d.Get("name").(string))
+	cluster, err := getCluster(client, d.Id())
 
 	if err != nil {
-		return errors.New("UNABLE TO RETRIEVE DETAILS FOR CLUSTER: " + d.Get("name").(string))
+		if isNotFound(err) {
+			log.Printf("[WARN] Cluster %s no longer exists in CCP, removing from state", d.Id())
+			d.SetId("")
+			return nil
+		}
+		return errors.New("UNABLE TO RETRIEVE DETAILS FOR CLUSTER: " + d.Get("name").(string) + ": " + err.Error())
 	}
 
 	return setClusterResourceData(d, cluster)
@@ -671,69 +708,549 @@ func resourceClusterUpdate(d *schema.ResourceData, m interface{}) error {
 
 	client := m.(*ccp.Client)
 
-	newCluster := ccp.Cluster{
-		LoadBalancerIPNum: ccp.Int64(int64(d.Get("loadbalancer_ip_num").(int))),
+	// the update timeout covers the whole update, so every wait below gets whatever is left of it
+	deadline := time.Now().Add(d.Timeout(schema.TimeoutUpdate))
+
+	// only the steps that have completed are saved to the state if the update fails part way, so the
+	// rest are tried again on the next apply
+	d.Partial(true)
+
+	// only the fields that have changed are sent so everything else is left as it is in CCP
+	var newCluster ccp.Cluster
+	var changed []string
+
+	if d.HasChange("loadbalancer_ip_num") {
+		newCluster.LoadBalancerIPNum = ccp.Int64(int64(d.Get("loadbalancer_ip_num").(int)))
+		changed = append(changed, "loadbalancer_ip_num")
+	}
+	if d.HasChange("description") {
+		newCluster.Description = ccp.String(d.Get("description").(string))
+		changed = append(changed, "description")
+	}
+	if d.HasChange("ntp_pools") {
+		newCluster.NTPPools = expandStringList(d.Get("ntp_pools").([]interface{}))
+		changed = append(changed, "ntp_pools")
+	}
+	if d.HasChange("ntp_servers") {
+		newCluster.NTPServers = expandStringList(d.Get("ntp_servers").([]interface{}))
+		changed = append(changed, "ntp_servers")
+	}
+	if d.HasChange("registries_root_ca") {
+		newCluster.RegistriesRootCA = expandStringList(d.Get("registries_root_ca").([]interface{}))
+		changed = append(changed, "registries_root_ca")
+	}
+	if d.HasChange("registries_insecure") {
+		newCluster.RegistriesInsecure = expandStringList(d.Get("registries_insecure").([]interface{}))
+		changed = append(changed, "registries_insecure")
 	}
+	if d.HasChange("docker_proxy_http") {
+		newCluster.DockerProxyHTTP = ccp.String(d.Get("docker_proxy_http").(string))
+		changed = append(changed, "docker_proxy_http")
+	}
+	if d.HasChange("docker_proxy_https") {
+		newCluster.DockerProxyHTTPS = ccp.String(d.Get("docker_proxy_https").(string))
+		changed = append(changed, "docker_proxy_https")
+	}
+	if d.HasChange("docker_no_proxy") {
+		newCluster.DockerNoProxy = expandStringList(d.Get("docker_no_proxy").([]interface{}))
+		changed = append(changed, "docker_no_proxy")
+	}
+
+	if len(changed) > 0 {
+		if _, err := client.PatchCluster(&newCluster, d.Id()); err != nil {
+			return errors.New("UNABLE TO UPDATE CLUSTER " + d.Get("name").(string) + ": " + err.Error())
+		}
+
+		for _, key := range changed {
+			d.SetPartial(key)
+		}
+	}
+
+	// the control plane has to be upgraded before any of the worker node pools
+	if d.HasChange("kubernetes_version") || d.HasChange("master_node_pool.0.kubernetes_version") || d.HasChange("master_node_pool.0.template") {
+		if err := upgradeControlPlane(client, d, deadline); err != nil {
+			return err
+		}
 
-	cluster, err := client.PatchCluster(&newCluster, d.Get("uuid").(string))
+		d.SetPartial("kubernetes_version")
+		d.SetPartial("master_node_pool")
+	}
+
+	if d.HasChange("worker_node_pools") {
+		if err := updateWorkerNodePools(client, d, deadline); err != nil {
+			refreshWorkerNodePools(client, d)
+			return err
+		}
+
+		d.SetPartial("worker_node_pools")
+	}
+
+	d.Partial(false)
+
+	cluster, err := getCluster(client, d.Id())
 
 	if err != nil {
-		return errors.New(err.Error())
+		return errors.New("UNABLE TO RETRIEVE DETAILS FOR CLUSTER: " + d.Get("name").(string) + ": " + err.Error())
+	}
+
+	return setClusterResourceData(d, cluster)
+
+}
+
+func upgradeControlPlane(client *ccp.Client, d *schema.ResourceData, deadline time.Time) error {
+
+	log.Printf("[INFO] Upgrading control plane of cluster %s to %s", d.Id(), d.Get("kubernetes_version").(string))
+
+	upgrade := ccp.Cluster{
+		KubernetesVersion: ccp.String(d.Get("kubernetes_version").(string)),
+		MasterNodePool: &ccp.MasterNodePool{
+			Template:          ccp.String(d.Get("master_node_pool.0.template").(string)),
+			KubernetesVersion: ccp.String(d.Get("master_node_pool.0.kubernetes_version").(string)),
+		},
+	}
+
+	if _, err := client.PatchCluster(&upgrade, d.Id()); err != nil {
+		return errors.New("UNABLE TO UPGRADE CLUSTER " + d.Get("name").(string) + ": " + err.Error())
+	}
+
+	if _, err := waitForCluster(client, d.Id(), time.Until(deadline)); err != nil {
+		return errors.New("ERROR UPGRADING CLUSTER " + d.Get("name").(string) + ": " + err.Error())
+	}
+
+	return nil
+}
+
+// updateWorkerNodePools matches the old and new worker node pools by name rather than by their position
+// in the list, so pools can be added, removed and scaled independently of each other
+func updateWorkerNodePools(client *ccp.Client, d *schema.ResourceData, deadline time.Time) error {
+
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+
+	o, n := d.GetChange("worker_node_pools")
+
+	oldPools, err := workerNodePoolsByName(o.([]interface{}))
+
+	if err != nil {
+		return err
 	}
 
-	cluster, err = client.GetClusterByName(d.Get("name").(string))
+	newPools, err := workerNodePoolsByName(n.([]interface{}))
 
 	if err != nil {
-		return errors.New("UNABLE TO RETRIEVE DETAILS FOR CLUSTER: " + d.Get("name").(string))
+		return err
 	}
 
-	// only supporting 1 worker node pool (worker_node_pools.0) at the moment
+	for _, pool := range o.([]interface{}) {
+		name := pool.(map[string]interface{})["name"].(string)
 
-	if d.HasChange("worker_node_pools.0.size") {
-		_, newValue := d.GetChange("worker_node_pools.0.size")
-		cluster, err = client.ScaleCluster(d.Get("uuid").(string), d.Get("worker_node_pools.0.name").(string), newValue.(int))
+		if _, ok := newPools[name]; ok {
+			continue
+		}
+
+		log.Printf("[INFO] Removing worker node pool %s from cluster %s", name, d.Id())
+
+		if err := deleteNodePool(ctx, client, d.Id(), name); err != nil && !isNotFound(err) {
+			return errors.New("UNABLE TO DELETE WORKER NODE POOL " + name + ": " + err.Error())
+		}
+	}
+
+	var resized []map[string]interface{}
+
+	for _, pool := range n.([]interface{}) {
+		newPool := pool.(map[string]interface{})
+		name := newPool["name"].(string)
+
+		oldPool, ok := oldPools[name]
+
+		if !ok {
+			log.Printf("[INFO] Adding worker node pool %s to cluster %s", name, d.Id())
+
+			workerNodePool := expandWorkerNodePool(newPool)
+
+			if err := addNodePool(client, d.Id(), &workerNodePool); err != nil {
+				return errors.New("UNABLE TO ADD WORKER NODE POOL " + name + ": " + err.Error())
+			}
+
+			resized = append(resized, newPool)
+			continue
+		}
+
+		if oldPool["kubernetes_version"].(string) != newPool["kubernetes_version"].(string) || oldPool["template"].(string) != newPool["template"].(string) {
+			log.Printf("[INFO] Upgrading worker node pool %s in cluster %s to %s using %s", name, d.Id(), newPool["kubernetes_version"].(string), newPool["template"].(string))
+
+			upgrade := ccp.WorkerNodePool{
+				Template:          ccp.String(newPool["template"].(string)),
+				KubernetesVersion: ccp.String(newPool["kubernetes_version"].(string)),
+			}
+
+			if err := patchNodePool(client, d.Id(), name, &upgrade); err != nil {
+				return errors.New("UNABLE TO UPGRADE WORKER NODE POOL " + name + ": " + err.Error())
+			}
+
+			if _, err := waitForCluster(client, d.Id(), time.Until(deadline)); err != nil {
+				return errors.New("ERROR UPGRADING WORKER NODE POOL " + name + ": " + err.Error())
+			}
+		}
+
+		if oldPool["size"].(int) != newPool["size"].(int) {
+			log.Printf("[INFO] Scaling worker node pool %s in cluster %s to %d", name, d.Id(), newPool["size"].(int))
+
+			if _, err := client.ScaleCluster(d.Id(), name, newPool["size"].(int)); err != nil {
+				return errors.New("UNABLE TO SCALE WORKER NODE POOL " + name + ": " + err.Error())
+			}
+
+			resized = append(resized, newPool)
+		}
+	}
+
+	// CCP returns as soon as the change is accepted so wait for the nodes to be added or removed
+	for _, pool := range resized {
+		if err := waitForNodePool(client, d.Id(), pool["name"].(string), pool["size"].(int), time.Until(deadline)); err != nil {
+			return err
+		}
 	}
 
+	return nil
+}
+
+// refreshWorkerNodePools saves the worker node pools as CCP has them after updateWorkerNodePools has failed,
+// as some of the pools may have been added, removed or scaled before the error
+func refreshWorkerNodePools(client *ccp.Client, d *schema.ResourceData) {
+
+	o, n := d.GetChange("worker_node_pools")
+
+	newPools, err := workerNodePoolsByName(n.([]interface{}))
+
 	if err != nil {
-		return errors.New("UNABLE TO RETRIEVE DETAILS FOR CLUSTER: " + d.Get("name").(string))
+		return
 	}
 
-	cluster, err = client.GetClusterByName(d.Get("name").(string))
+	// pools that were meant to be removed stay in the state until they have gone from CCP
+	pools := n.([]interface{})
+
+	for _, pool := range o.([]interface{}) {
+		if _, ok := newPools[pool.(map[string]interface{})["name"].(string)]; !ok {
+			pools = append(pools, pool)
+		}
+	}
+
+	cluster, err := getCluster(client, d.Id())
 
 	if err != nil {
-		return errors.New("UNABLE TO RETRIEVE DETAILS FOR CLUSTER: " + d.Get("name").(string))
+		log.Printf("[WARN] Unable to read back the worker node pools of cluster %s: %s", d.Id(), err)
+		return
 	}
 
-	return setClusterResourceData(d, cluster)
+	workerPoolOut, err := flattenWorkerNodePools(pools, cluster)
+
+	if err != nil {
+		log.Printf("[WARN] Unable to read back the worker node pools of cluster %s: %s", d.Id(), err)
+		return
+	}
+
+	if err := d.Set("worker_node_pools", workerPoolOut); err == nil {
+		d.SetPartial("worker_node_pools")
+	}
+}
+
+func workerNodePoolsByName(pools []interface{}) (map[string]map[string]interface{}, error) {
+
+	byName := make(map[string]map[string]interface{})
+
+	for _, pool := range pools {
+		workerPool := pool.(map[string]interface{})
+		name := workerPool["name"].(string)
+
+		if _, ok := byName[name]; ok {
+			return nil, errors.New("WORKER NODE POOL NAMES MUST BE UNIQUE: " + name)
+		}
+
+		byName[name] = workerPool
+	}
+
+	return byName, nil
+}
+
+func expandWorkerNodePool(worker map[string]interface{}) ccp.WorkerNodePool {
+
+	gpuKeys := worker["gpus"].([]interface{})
+
+	var gpus []string
+
+	for _, gpu := range gpuKeys {
+		gpus = append(gpus, gpu.(string))
+	}
+
+	var nodePool []ccp.Node
+	nodeKeys := worker["nodes"].([]interface{})
+
+	for _, node := range nodeKeys {
+
+		tmpNode := node.(map[string]interface{})
+
+		nodes := ccp.Node{
+			Name:         ccp.String(tmpNode["name"].(string)),
+			Status:       ccp.String(tmpNode["status"].(string)),
+			StatusDetail: ccp.String(tmpNode["status_detail"].(string)),
+			StatusReason: ccp.String(tmpNode["status_reason"].(string)),
+			PublicIP:     ccp.String(tmpNode["public_ip"].(string)),
+			PrivateIP:    ccp.String(tmpNode["private_ip"].(string)),
+			Phase:        ccp.String(tmpNode["phase"].(string)),
+		}
+
+		nodePool = append(nodePool, nodes)
+	}
+
+	if len(nodePool) == 0 {
+		nodePool = nil
+	}
+
+	return ccp.WorkerNodePool{
+		Name:     ccp.String(worker["name"].(string)),
+		Size:     ccp.Int64(int64(worker["size"].(int))),
+		Template: ccp.String(worker["template"].(string)),
+		VCPUs:    ccp.Int64(int64(worker["vcpus"].(int))),
+		Memory:   ccp.Int64(int64(worker["memory"].(int))),
+		//GPUs:              &gpus,
+		SSHUser:           ccp.String(worker["ssh_user"].(string)),
+		SSHKey:            ccp.String(worker["ssh_key"].(string)),
+		Nodes:             &nodePool,
+		KubernetesVersion: ccp.String(worker["kubernetes_version"].(string)),
+	}
+}
+
+// validateClusterNetwork checks the settings that each network plugin and IP allocation method depend on
+func validateClusterNetwork(d *schema.ResourceDiff, m interface{}) error {
+
+	switch d.Get("network_plugin.0.name").(string) {
+	case "contiv-aci":
+		if d.NewValueKnown("aci_profile_uuid") && d.Get("aci_profile_uuid").(string) == "" {
+			return errors.New("aci_profile_uuid: REQUIRED WHEN network_plugin.name IS contiv-aci")
+		}
+		if d.NewValueKnown("routable_cidr") && d.Get("routable_cidr").(string) == "" {
+			return errors.New("routable_cidr: REQUIRED WHEN network_plugin.name IS contiv-aci")
+		}
+	case "calico":
+		if d.NewValueKnown("network_plugin.0.details.0.pod_cidr") && d.Get("network_plugin.0.details.0.pod_cidr").(string) == "" {
+			return errors.New("network_plugin.details.pod_cidr: REQUIRED WHEN network_plugin.name IS calico")
+		}
+	}
+
+	if d.Get("ip_allocation_method").(string) == "ccpnet" && d.NewValueKnown("subnet_uuid") && d.Get("subnet_uuid").(string) == "" {
+		return errors.New("subnet_uuid: REQUIRED WHEN ip_allocation_method IS ccpnet")
+	}
+
+	return nil
+}
+
+// validateClusterNodePools checks the master node pool can keep etcd quorum and the worker node pools can be told apart
+func validateClusterNodePools(d *schema.ResourceDiff, m interface{}) error {
+
+	if d.NewValueKnown("master_node_pool.0.size") {
+		if size := d.Get("master_node_pool.0.size").(int); size != 1 && size != 3 {
+			return errors.New("master_node_pool.size: MUST BE 1 OR 3, GOT " + strconv.Itoa(size))
+		}
+	}
+
+	if _, err := workerNodePoolsByName(d.Get("worker_node_pools").([]interface{})); err != nil {
+		return errors.New("worker_node_pools.name: " + err.Error())
+	}
+
+	return nil
+}
+
+// validateWorkerNodePoolChanges rejects changes to the worker node pool settings CCP can only set when a pool
+// is added, as updating the cluster has no way to apply them
+func validateWorkerNodePoolChanges(d *schema.ResourceDiff, m interface{}) error {
+
+	if d.Id() == "" {
+		return nil
+	}
+
+	o, _ := d.GetChange("worker_node_pools")
+
+	oldPools, err := workerNodePoolsByName(o.([]interface{}))
+
+	if err != nil {
+		return err
+	}
+
+	for i, pool := range d.Get("worker_node_pools").([]interface{}) {
+		newPool := pool.(map[string]interface{})
+		name := newPool["name"].(string)
+
+		oldPool, ok := oldPools[name]
+
+		if !ok {
+			continue
+		}
+
+		for _, field := range []string{"vcpus", "memory", "ssh_user", "ssh_key", "gpus"} {
+			key := "worker_node_pools." + strconv.Itoa(i) + "." + field
+
+			if !d.NewValueKnown(key) {
+				continue
+			}
+
+			if !sameValue(oldPool[field], newPool[field]) {
+				return errors.New(key + ": CAN'T BE CHANGED ON THE EXISTING WORKER NODE POOL " + name + ", GIVE THE POOL A NEW NAME TO REPLACE IT")
+			}
+		}
+	}
+
+	return nil
+}
+
+// sameValue compares two values read from the diff, treating a missing list the same as an empty one
+func sameValue(a interface{}, b interface{}) bool {
+
+	if l, ok := a.([]interface{}); ok && len(l) == 0 {
+		a = nil
+	}
+
+	if l, ok := b.([]interface{}); ok && len(l) == 0 {
+		b = nil
+	}
 
+	return reflect.DeepEqual(a, b)
+}
+
+// validateClusterUpgrade rejects Kubernetes version changes that CCP can't make to an existing cluster
+func validateClusterUpgrade(d *schema.ResourceDiff, m interface{}) error {
+
+	// the control plane is upgraded with both versions in a single request so they have to agree
+	if d.NewValueKnown("kubernetes_version") && d.NewValueKnown("master_node_pool.0.kubernetes_version") {
+		version := d.Get("kubernetes_version").(string)
+		masterVersion := d.Get("master_node_pool.0.kubernetes_version").(string)
+
+		if strings.TrimPrefix(version, "v") != strings.TrimPrefix(masterVersion, "v") {
+			return errors.New("master_node_pool.kubernetes_version: MUST MATCH THE CLUSTER kubernetes_version " + version + ", GOT " + masterVersion)
+		}
+	}
+
+	if d.Id() == "" {
+		return nil
+	}
+
+	if d.HasChange("kubernetes_version") && d.NewValueKnown("kubernetes_version") {
+		o, n := d.GetChange("kubernetes_version")
+
+		if err := validateKubernetesUpgrade(o.(string), n.(string)); err != nil {
+			return errors.New("kubernetes_version: " + err.Error())
+		}
+	}
+
+	if d.HasChange("master_node_pool.0.kubernetes_version") && d.NewValueKnown("master_node_pool.0.kubernetes_version") {
+		o, n := d.GetChange("master_node_pool.0.kubernetes_version")
+
+		if err := validateKubernetesUpgrade(o.(string), n.(string)); err != nil {
+			return errors.New("master_node_pool.kubernetes_version: " + err.Error())
+		}
+	}
+
+	o, n := d.GetChange("worker_node_pools")
+
+	oldPools, err := workerNodePoolsByName(o.([]interface{}))
+
+	if err != nil {
+		return err
+	}
+
+	for i, pool := range n.([]interface{}) {
+		newPool := pool.(map[string]interface{})
+		name := newPool["name"].(string)
+
+		if !d.NewValueKnown("worker_node_pools." + strconv.Itoa(i) + ".kubernetes_version") {
+			continue
+		}
+
+		if oldPool, ok := oldPools[name]; ok {
+			if err := validateKubernetesUpgrade(oldPool["kubernetes_version"].(string), newPool["kubernetes_version"].(string)); err != nil {
+				return errors.New("worker_node_pools." + name + ".kubernetes_version: " + err.Error())
+			}
+		}
+	}
+
+	return nil
 }
 
 func resourceClusterDelete(d *schema.ResourceData, m interface{}) error {
 
 	client := m.(*ccp.Client)
 
-	err := client.DeleteCluster(d.Get("uuid").(string))
+	start := time.Now()
 
-	if err != nil {
+	ctx, cancel := context.WithTimeout(context.Background(), d.Timeout(schema.TimeoutDelete))
+	defer cancel()
+
+	err := deleteCluster(ctx, client, d.Id())
+
+	if ctx.Err() == context.DeadlineExceeded {
+		return errors.New("TIMED OUT AFTER " + d.Timeout(schema.TimeoutDelete).String() + " WAITING FOR CLUSTER " + d.Get("name").(string) + " TO BE DELETED, LAST STATUS: " + clusterStatus(client, d.Id()))
+	}
+
+	if err != nil && !isNotFound(err) {
 		return errors.New(err.Error())
 	}
 
+	// CCP carries on deleting the cluster in the background, wait for it to go so a cluster with
+	// the same name can be created straight afterwards
+	if err := waitForClusterDeleted(client, d.Id(), d.Timeout(schema.TimeoutDelete)-time.Since(start)); err != nil {
+		return errors.New("ERROR DELETING CLUSTER " + d.Get("name").(string) + ": " + err.Error())
+	}
+
 	d.SetId("")
 	return nil
 }
 
+// resourceClusterImport accepts either the UUID of the cluster or name:<cluster name>
+func resourceClusterImport(d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+
+	client := m.(*ccp.Client)
+
+	var cluster *ccp.Cluster
+	var err error
+
+	if strings.HasPrefix(d.Id(), "name:") {
+		cluster, err = client.GetClusterByName(strings.TrimPrefix(d.Id(), "name:"))
+	} else {
+		cluster, err = getCluster(client, d.Id())
+	}
+
+	if err != nil {
+		return nil, errors.New("UNABLE TO IMPORT CLUSTER " + d.Id() + ": " + err.Error())
+	}
+
+	if cluster.UUID == nil {
+		return nil, malformedClusterError("uuid")
+	}
+
+	d.SetId(*cluster.UUID)
+
+	if err := setClusterResourceData(d, cluster); err != nil {
+		return nil, err
+	}
+
+	return []*schema.ResourceData{d}, nil
+}
+
 func setClusterResourceData(d *schema.ResourceData, u *ccp.Cluster) error {
 
+	if u == nil {
+		return errors.New("MALFORMED CLUSTER RESPONSE FROM CCP: NO CLUSTER RETURNED")
+	}
+
 	if err := d.Set("uuid", u.UUID); err != nil {
 		return errors.New("CANNOT SET UUID")
 	}
-	if err := d.Set("type", u.Type); err != nil {
+	if err := setReturnedString(d, "type", u.Type); err != nil {
 		return errors.New("CANNOT SET TYPE")
 	}
-	if err := d.Set("name", u.Name); err != nil {
+	if err := setReturnedString(d, "name", u.Name); err != nil {
 		return errors.New("CANNOT SET NAME")
 	}
-	if err := d.Set("provider_client_config_uuid", u.InfraProviderUUID); err != nil {
+	if err := setReturnedString(d, "provider_client_config_uuid", u.InfraProviderUUID); err != nil {
 		return errors.New("CANNOT SET PROVIDER CLIENT CONFIG UUID")
 	}
 	if err := d.Set("status", u.Status); err != nil {
@@ -745,7 +1262,7 @@ func setClusterResourceData(d *schema.ResourceData, u *ccp.Cluster) error {
 	if err := d.Set("kube_config", u.KubeConfig); err != nil {
 		return errors.New("CANNOT SET KUBECONFIG")
 	}
-	if err := d.Set("ip_allocation_method", u.IPAllocationMethod); err != nil {
+	if err := setReturnedString(d, "ip_allocation_method", u.IPAllocationMethod); err != nil {
 		return errors.New("CANNOT SET IP ALLOCATION METHOD")
 	}
 	if err := d.Set("master_vip", u.MasterVIP); err != nil {
@@ -754,7 +1271,7 @@ func setClusterResourceData(d *schema.ResourceData, u *ccp.Cluster) error {
 	if err := d.Set("loadbalancer_ip_num", u.LoadBalancerIPNum); err != nil {
 		return errors.New("CANNOT SET NUMBER OF LOAD BALANCERS")
 	}
-	if err := d.Set("subnet_uuid", u.SubnetUUID); err != nil {
+	if err := setReturnedString(d, "subnet_uuid", u.SubnetUUID); err != nil {
 		return errors.New("CANNOT SET SUBNET ID")
 	}
 	if err := d.Set("ntp_pools", u.NTPPools); err != nil {
@@ -778,191 +1295,304 @@ func setClusterResourceData(d *schema.ResourceData, u *ccp.Cluster) error {
 	if err := d.Set("docker_proxy_https", u.DockerProxyHTTPS); err != nil {
 		return errors.New("CANNOT SET HTTPS DOCKER PROXY")
 	}
-	if err := d.Set("docker_bip", u.DockerBIP); err != nil {
+	if err := setReturnedString(d, "docker_bip", u.DockerBIP); err != nil {
 		return errors.New("CANNOT SET DOCKER BIP")
 	}
 
-	infraNetworksOut := make([]interface{}, 0, 0)
-
-	infraNetworksIn := (*u.Infra.Networks)[0]
+	if u.Infra == nil {
+		return malformedClusterError("infra")
+	}
 
-	infraNetworksOut = append(infraNetworksOut, infraNetworksIn)
+	infraOut := flattenInfra(d, u.Infra)
 
-	infraOut := make([]interface{}, 0, 0)
+	if err := d.Set("infra", infraOut); err != nil {
+		return errors.New("CANNOT SET INFRA")
+	}
 
-	infraIn := make(map[string]interface{})
+	if u.MasterNodePool == nil {
+		return malformedClusterError("master_node_pool")
+	}
 
-	if u.Infra.ResourcePool == nil {
-		infraIn["resource_pool"] = ""
-	} else {
-		infraIn["resource_pool"] = *u.Infra.ResourcePool
+	if u.MasterNodePool.Name == nil {
+		return malformedClusterError("master_node_pool.name")
 	}
 
-	infraIn["datacenter"] = *u.Infra.Datacenter
-	infraIn["cluster"] = *u.Infra.Cluster
-	infraIn["datastore"] = *u.Infra.Datastore
+	masterPoolIn := make(map[string]interface{})
 
-	infraIn["networks"] = infraNetworksOut
+	masterPoolIn["name"] = *u.MasterNodePool.Name
+	masterPoolIn["memory"] = returnedInt(d, "master_node_pool.0.memory", u.MasterNodePool.Memory)
+	masterPoolIn["size"] = returnedInt(d, "master_node_pool.0.size", u.MasterNodePool.Size)
+	masterPoolIn["vcpus"] = returnedInt(d, "master_node_pool.0.vcpus", u.MasterNodePool.VCPUs)
+	masterPoolIn["kubernetes_version"] = returnedString(d, "master_node_pool.0.kubernetes_version", u.MasterNodePool.KubernetesVersion)
+	masterPoolIn["ssh_user"] = returnedString(d, "master_node_pool.0.ssh_user", u.MasterNodePool.SSHUser)
+	masterPoolIn["ssh_key"] = returnedString(d, "master_node_pool.0.ssh_key", u.MasterNodePool.SSHKey)
+	masterPoolIn["template"] = returnedString(d, "master_node_pool.0.template", u.MasterNodePool.Template)
+
+	// CCP doesn't return the GPUs of a pool so they are carried over from the state
+	masterPoolIn["gpus"] = d.Get("master_node_pool.0.gpus")
+	masterPoolIn["nodes"] = flattenNodes(u.MasterNodePool.Nodes)
+
+	if err := d.Set("master_node_pool", []interface{}{masterPoolIn}); err != nil {
+		return errors.New("CANNOT SET master NODE POOL")
+	}
 
-	infraOut = append(infraOut, infraIn)
+	workerPoolOut, err := flattenWorkerNodePools(d.Get("worker_node_pools").([]interface{}), u)
 
-	if err := d.Set("infra", infraOut); err != nil {
-		return errors.New("CANNOT SET INFRA")
+	if err != nil {
+		return err
 	}
 
-	masterPoolNodesOut := make([]interface{}, 0, 0)
-	masterPoolNodesIn := make(map[string]interface{})
+	if err := d.Set("worker_node_pools", workerPoolOut); err != nil {
+		return errors.New("CANNOT SET worker NODE POOL")
+	}
 
-	for _, node := range *u.MasterNodePool.Nodes {
-		masterPoolNodesIn["name"] = *node.Name
-		masterPoolNodesIn["status"] = *node.Status
+	if u.NetworkPlugin == nil {
+		return malformedClusterError("network_plugin")
+	}
 
-		if node.StatusDetail != nil {
-			masterPoolNodesIn["status_detail"] = *node.StatusDetail
-		}
+	if u.NetworkPlugin.Name == nil {
+		return malformedClusterError("network_plugin.name")
+	}
 
-		if node.StatusReason != nil {
-			masterPoolNodesIn["status_reason"] = *node.StatusReason
-		}
+	// ACI clusters don't have a pod CIDR so CCP leaves it out, in which case the one in the state is kept
+	podCIDR := d.Get("network_plugin.0.details.0.pod_cidr").(string)
 
-		masterPoolNodesIn["private_ip"] = *node.PrivateIP
-		masterPoolNodesIn["public_ip"] = *node.PublicIP
-		masterPoolNodesIn["phase"] = *node.Phase
+	if u.NetworkPlugin.Details != nil && u.NetworkPlugin.Details.PodCIDR != nil {
+		podCIDR = *u.NetworkPlugin.Details.PodCIDR
+	}
+
+	networkPluginOut := []interface{}{
+		map[string]interface{}{
+			"name": *u.NetworkPlugin.Name,
+			"details": []interface{}{
+				map[string]interface{}{
+					"pod_cidr": podCIDR,
+				},
+			},
+		},
+	}
 
-		masterPoolNodesOut = append(masterPoolNodesOut, masterPoolNodesIn)
+	if err := d.Set("network_plugin", networkPluginOut); err != nil {
+		return errors.New("CANNOT SET NETWORK PLUGIN")
 	}
 
-	masterPoolOut := make([]interface{}, 0, 0)
+	if err := setReturnedBool(d, "ingress_as_lb", u.IngressAsLB); err != nil {
+		return errors.New("CANNOT SET INGRESS AS LB VALUE")
+	}
+	if err := setReturnedString(d, "nginx_ingress_class", u.NginxIngressClass); err != nil {
+		return errors.New("CANNOT SET NGINX INGRESS CLASS")
+	}
+	if err := setReturnedBool(d, "etcd_encrypted", u.ETCDEncrypted); err != nil {
+		return errors.New("CANNOT SET ETCD ENCRYPTED")
+	}
+	if err := setReturnedBool(d, "skip_management", u.SkipManagement); err != nil {
+		return errors.New("CANNOT SET SKIP MANAGEMENT VALUE")
+	}
+	if err := d.Set("docker_no_proxy", u.DockerNoProxy); err != nil {
+		return errors.New("CANNOT SET DOCKER NO PROXY")
+	}
+	if err := setReturnedString(d, "routable_cidr", u.RoutableCIDR); err != nil {
+		return errors.New("CANNOT SET ROUTABLE CIDR")
+	}
+	if err := setReturnedString(d, "image_prefix", u.ImagePrefix); err != nil {
+		return errors.New("CANNOT SET IMAGE PREFIX")
+	}
+	if err := setReturnedString(d, "aci_profile_uuid", u.ACIProfileUUID); err != nil {
+		return errors.New("CANNOT SET ACI PROFILE UUID")
+	}
+	if err := d.Set("description", u.Description); err != nil {
+		return errors.New("CANNOT SET DESCRIPTION")
+	}
+	if err := setReturnedBool(d, "aws_iam_enabled", u.AWSIamEnabled); err != nil {
+		return errors.New("CANNOT SET AWS IAM VALUE")
+	}
 
-	masterPoolIn := make(map[string]interface{})
+	return nil
+}
 
-	masterPoolIn["name"] = *u.MasterNodePool.Name
-	masterPoolIn["memory"] = *u.MasterNodePool.Memory
-	masterPoolIn["size"] = *u.MasterNodePool.Size
-	masterPoolIn["vcpus"] = *u.MasterNodePool.VCPUs
-	masterPoolIn["kubernetes_version"] = *u.MasterNodePool.KubernetesVersion
-	masterPoolIn["ssh_user"] = *u.MasterNodePool.SSHUser
-	masterPoolIn["ssh_key"] = *u.MasterNodePool.SSHKey
-	masterPoolIn["template"] = *u.MasterNodePool.Template
+// flattenInfra returns every network CCP has for the cluster in the order they are returned, which is the
+// order they were given in when the cluster was created. Any field CCP leaves out keeps the value in the
+// state, as infra can't be changed and an empty value would replace the cluster.
+func flattenInfra(d *schema.ResourceData, infra *ccp.Infra) []interface{} {
 
-	masterPoolIn["nodes"] = masterPoolNodesOut
+	networksOut := d.Get("infra.0.networks").([]interface{})
 
-	masterPoolOut = append(masterPoolOut, masterPoolIn)
+	if infra.Networks != nil {
+		networksOut = make([]interface{}, 0, len(*infra.Networks))
 
-	if err := d.Set("master_node_pool", masterPoolOut); err != nil {
-		return errors.New("CANNOT SET master NODE POOL")
+		for _, network := range *infra.Networks {
+			networksOut = append(networksOut, network)
+		}
 	}
 
-	workerPoolOut := make([]interface{}, 0, 0)
-	workerPoolIn := make(map[string]interface{})
+	return []interface{}{
+		map[string]interface{}{
+			"datacenter":    returnedString(d, "infra.0.datacenter", infra.Datacenter),
+			"cluster":       returnedString(d, "infra.0.cluster", infra.Cluster),
+			"datastore":     returnedString(d, "infra.0.datastore", infra.Datastore),
+			"resource_pool": returnedString(d, "infra.0.resource_pool", infra.ResourcePool),
+			"networks":      networksOut,
+		},
+	}
+}
 
-	workerPoolNodesOut := make([]interface{}, 0, 10)
+// returnedString is the value CCP returned for key, or the value already in the state if it was left out
+func returnedString(d *schema.ResourceData, key string, s *string) string {
 
-	for _, workerNode := range *u.WorkerNodePool {
+	if s == nil {
+		return d.Get(key).(string)
+	}
 
-		workerPoolIn = make(map[string]interface{})
+	return *s
+}
 
-		for _, node := range *workerNode.Nodes {
-			workerPoolNodesIn := make(map[string]interface{})
-			if node.Name != nil {
-				workerPoolNodesIn["name"] = *node.Name
-			}
+// returnedInt is the value CCP returned for key, or the value already in the state if it was left out
+func returnedInt(d *schema.ResourceData, key string, i *int64) int {
 
-			if node.Status != nil {
-				workerPoolNodesIn["status"] = *node.Status
-			}
+	if i == nil {
+		return d.Get(key).(int)
+	}
 
-			if node.StatusDetail != nil {
-				workerPoolNodesIn["status_detail"] = *node.StatusDetail
-			}
+	return int(*i)
+}
 
-			if node.StatusReason != nil {
-				workerPoolNodesIn["status_reason"] = *node.StatusReason
-			}
+// setReturnedString is used for the attributes that replace the cluster when they change, so that a field
+// left out of a response doesn't show up as a change
+func setReturnedString(d *schema.ResourceData, key string, s *string) error {
 
-			if node.PrivateIP != nil {
-				workerPoolNodesIn["private_ip"] = *node.PrivateIP
-			}
+	return d.Set(key, returnedString(d, key, s))
+}
 
-			if node.PublicIP != nil {
-				workerPoolNodesIn["public_ip"] = *node.PublicIP
-			}
+func setReturnedBool(d *schema.ResourceData, key string, b *bool) error {
 
-			if node.Phase != nil {
-				workerPoolNodesIn["phase"] = *node.Phase
-			}
+	if b == nil {
+		return nil
+	}
 
-			workerPoolNodesOut = append(workerPoolNodesOut, workerPoolNodesIn)
-		}
+	return d.Set(key, *b)
+}
 
-		workerPoolIn["name"] = *workerNode.Name
-		workerPoolIn["memory"] = *workerNode.Memory
-		workerPoolIn["size"] = *workerNode.Size
-		workerPoolIn["vcpus"] = *workerNode.VCPUs
-		workerPoolIn["kubernetes_version"] = *workerNode.KubernetesVersion
-		workerPoolIn["ssh_user"] = *workerNode.SSHUser
-		workerPoolIn["ssh_key"] = *workerNode.SSHKey
-		workerPoolIn["template"] = *workerNode.Template
+// flattenWorkerNodePools returns the pools in prior as CCP has them. Each pool starts from prior, so the GPUs
+// (which CCP doesn't return) and any field left out of the response are kept instead of showing up as a change.
+func flattenWorkerNodePools(prior []interface{}, u *ccp.Cluster) ([]interface{}, error) {
 
-		workerPoolIn["nodes"] = workerPoolNodesOut
+	var workerNodePools []ccp.WorkerNodePool
 
-		workerPoolOut = append(workerPoolOut, workerPoolIn)
+	if u.WorkerNodePool != nil {
+		workerNodePools = *u.WorkerNodePool
+	}
 
+	for i, workerNode := range workerNodePools {
+		if workerNode.Name == nil {
+			return nil, malformedClusterError("worker_node_pools." + strconv.Itoa(i) + ".name")
+		}
 	}
 
-	if err := d.Set("worker_node_pools", workerPoolOut); err != nil {
-		return errors.New("CANNOT SET worker NODE POOL")
+	priorWorkerPools, err := workerNodePoolsByName(prior)
+
+	if err != nil {
+		return nil, err
 	}
 
-	networkPluginDetailsOut := make([]interface{}, 0, 0)
-	networkPluginDetailsIn := make(map[string]interface{})
+	workerPoolOut := make([]interface{}, 0, 0)
 
-	networkPluginDetailsIn["pod_cidr"] = *u.NetworkPlugin.Details.PodCIDR
-	networkPluginDetailsOut = append(networkPluginDetailsOut, networkPluginDetailsIn)
+	for _, workerNode := range managedWorkerNodePools(prior, workerNodePools) {
 
-	networkPluginOut := make([]interface{}, 0, 0)
+		workerPoolIn := make(map[string]interface{})
 
-	networkPluginIn := make(map[string]interface{})
+		for k, v := range priorWorkerPools[*workerNode.Name] {
+			workerPoolIn[k] = v
+		}
 
-	networkPluginIn["name"] = *u.NetworkPlugin.Name
-	networkPluginIn["details"] = networkPluginDetailsOut
+		workerPoolIn["name"] = *workerNode.Name
 
-	networkPluginOut = append(networkPluginOut, networkPluginIn)
+		if workerNode.Memory != nil {
+			workerPoolIn["memory"] = *workerNode.Memory
+		}
+		if workerNode.Size != nil {
+			workerPoolIn["size"] = *workerNode.Size
+		}
+		if workerNode.VCPUs != nil {
+			workerPoolIn["vcpus"] = *workerNode.VCPUs
+		}
+		if workerNode.KubernetesVersion != nil {
+			workerPoolIn["kubernetes_version"] = *workerNode.KubernetesVersion
+		}
+		if workerNode.SSHUser != nil {
+			workerPoolIn["ssh_user"] = *workerNode.SSHUser
+		}
+		if workerNode.SSHKey != nil {
+			workerPoolIn["ssh_key"] = *workerNode.SSHKey
+		}
+		if workerNode.Template != nil {
+			workerPoolIn["template"] = *workerNode.Template
+		}
 
-	if err := d.Set("network_plugin", networkPluginOut); err != nil {
-		return errors.New("CANNOT SET NETWORK PLUGIN")
-	}
+		workerPoolIn["nodes"] = flattenNodes(workerNode.Nodes)
 
-	if err := d.Set("ingress_as_lb", u.IngressAsLB); err != nil {
-		return errors.New("CANNOT SET INGRESS AS LB VALUE")
-	}
-	if err := d.Set("nginx_ingress_class", u.NginxIngressClass); err != nil {
-		return errors.New("CANNOT SET NGINX INGRESS CLASS")
-	}
-	if err := d.Set("etcd_encrypted", u.ETCDEncrypted); err != nil {
-		return errors.New("CANNOT SET ETCD ENCRYPTED")
-	}
-	if err := d.Set("skip_management", u.SkipManagement); err != nil {
-		return errors.New("CANNOT SET SKIP MANAGEMENT VALUE")
-	}
-	if err := d.Set("docker_no_proxy", u.DockerNoProxy); err != nil {
-		return errors.New("CANNOT SET DOCKER NO PROXY")
+		workerPoolOut = append(workerPoolOut, workerPoolIn)
 	}
-	if err := d.Set("routable_cidr", u.RoutableCIDR); err != nil {
-		return errors.New("CANNOT SET ROUTABLE CIDR")
+
+	return workerPoolOut, nil
+}
+
+// managedWorkerNodePools returns the pools returned by CCP that are configured on this cluster, in the same
+// order as the configuration so a different ordering from the API doesn't show up as a diff. Pools that
+// aren't configured, such as those managed by ccp_node_pool, are left out, unless nothing is configured yet
+// as is the case on import.
+func managedWorkerNodePools(prior []interface{}, pools []ccp.WorkerNodePool) []ccp.WorkerNodePool {
+
+	position := make(map[string]int)
+
+	for i, pool := range prior {
+		if pool != nil {
+			position[pool.(map[string]interface{})["name"].(string)] = i
+		}
 	}
-	if err := d.Set("image_prefix", u.ImagePrefix); err != nil {
-		return errors.New("CANNOT SET IMAGE PREFIX")
+
+	if len(position) == 0 {
+		return pools
 	}
-	if err := d.Set("aci_profile_uuid", u.ACIProfileUUID); err != nil {
-		return errors.New("CANNOT SET ACI PROFILE UUID")
+
+	managed := make([]ccp.WorkerNodePool, 0, len(position))
+
+	for _, pool := range pools {
+		if _, ok := position[stringValue(pool.Name)]; ok {
+			managed = append(managed, pool)
+		}
 	}
-	if err := d.Set("description", u.Description); err != nil {
-		return errors.New("CANNOT SET DESCRIPTION")
+
+	sort.SliceStable(managed, func(i, j int) bool {
+		return position[stringValue(managed[i].Name)] < position[stringValue(managed[j].Name)]
+	})
+
+	return managed
+}
+
+func stringValue(s *string) string {
+
+	if s == nil {
+		return ""
 	}
-	if err := d.Set("aws_iam_enabled", u.AWSIamEnabled); err != nil {
-		return errors.New("CANNOT SET AWS IAM VALUE")
+
+	return *s
+}
+
+// malformedClusterError is returned when CCP leaves out a field that is needed to make sense of the cluster,
+// as opposed to details that haven't been filled in yet which are left empty
+func malformedClusterError(field string) error {
+	return errors.New("MALFORMED CLUSTER RESPONSE FROM CCP: " + field + " IS MISSING")
+}
+
+func expandStringList(list []interface{}) *[]string {
+
+	out := make([]string, 0, len(list))
+
+	for _, v := range list {
+		if v != nil {
+			out = append(out, v.(string))
+		}
 	}
 
-	return nil
+	return &out
 }
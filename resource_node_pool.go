@@ -0,0 +1,369 @@
+/*Copyright (c) 2019 Cisco and/or its affiliates.
+
+This software is licensed to you under the terms of the Cisco Sample
+Code License, Version 1.0 (the "License"). You may obtain a copy of the
+License at
+
+https://developer.cisco.com/docs/licenses
+
+All use of the material herein must be in accordance with the terms of
+the License. All rights not expressly granted by the License are
+reserved. Unless required by applicable law or agreed to separately in
+writing, software distributed under the License is distributed on an "AS
+IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+or implied.*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/ccp-client-library/ccp"
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/hashicorp/terraform/helper/validation"
+)
+
+func resourceNodePool() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceNodePoolCreate,
+		Read:   resourceNodePoolRead,
+		Update: resourceNodePoolUpdate,
+		Delete: resourceNodePoolDelete,
+
+		// node pools are identified by <cluster uuid>/<pool name>
+		Importer: &schema.ResourceImporter{
+			State: schema.ImportStatePassthrough,
+		},
+
+		CustomizeDiff: resourceNodePoolCustomizeDiff,
+
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(60 * time.Minute),
+			Update: schema.DefaultTimeout(60 * time.Minute),
+			Delete: schema.DefaultTimeout(30 * time.Minute),
+		},
+
+		Schema: map[string]*schema.Schema{
+			"cluster_uuid": &schema.Schema{
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+			},
+			"name": &schema.Schema{
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+			},
+			"size": &schema.Schema{
+				Type:         schema.TypeInt,
+				Required:     true,
+				ValidateFunc: validation.IntAtLeast(0),
+			},
+			"template": &schema.Schema{
+				Type:     schema.TypeString,
+				Required: true,
+			},
+			"vcpus": &schema.Schema{
+				Type:         schema.TypeInt,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.IntAtLeast(1),
+			},
+			"memory": &schema.Schema{
+				Type:         schema.TypeInt,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.IntAtLeast(1024),
+			},
+			"ssh_user": &schema.Schema{
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+			},
+			"ssh_key": &schema.Schema{
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validateSSHKey,
+				Sensitive:    true,
+			},
+			"kubernetes_version": &schema.Schema{
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validateKubernetesVersion,
+			},
+			"nodes": &schema.Schema{
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name": &schema.Schema{
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"status": &schema.Schema{
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"status_detail": &schema.Schema{
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"status_reason": &schema.Schema{
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"public_ip": &schema.Schema{
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"private_ip": &schema.Schema{
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"phase": &schema.Schema{
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func resourceNodePoolCreate(d *schema.ResourceData, m interface{}) error {
+
+	client := m.(*ccp.Client)
+
+	clusterUUID := d.Get("cluster_uuid").(string)
+	name := d.Get("name").(string)
+
+	workerNodePool := ccp.WorkerNodePool{
+		Name:              ccp.String(name),
+		Size:              ccp.Int64(int64(d.Get("size").(int))),
+		Template:          ccp.String(d.Get("template").(string)),
+		VCPUs:             ccp.Int64(int64(d.Get("vcpus").(int))),
+		Memory:            ccp.Int64(int64(d.Get("memory").(int))),
+		SSHUser:           ccp.String(d.Get("ssh_user").(string)),
+		SSHKey:            ccp.String(d.Get("ssh_key").(string)),
+		KubernetesVersion: ccp.String(d.Get("kubernetes_version").(string)),
+	}
+
+	if err := addNodePool(client, clusterUUID, &workerNodePool); err != nil {
+		return errors.New("UNABLE TO ADD NODE POOL " + name + " TO CLUSTER " + clusterUUID + ": " + err.Error())
+	}
+
+	d.SetId(clusterUUID + "/" + name)
+
+	if err := waitForNodePool(client, clusterUUID, name, d.Get("size").(int), d.Timeout(schema.TimeoutCreate)); err != nil {
+		return err
+	}
+
+	return resourceNodePoolRead(d, m)
+}
+
+func resourceNodePoolRead(d *schema.ResourceData, m interface{}) error {
+
+	client := m.(*ccp.Client)
+
+	clusterUUID, name, err := parseNodePoolID(d.Id())
+
+	if err != nil {
+		return err
+	}
+
+	cluster, err := getCluster(client, clusterUUID)
+
+	if err != nil {
+		if isNotFound(err) {
+			log.Printf("[WARN] Cluster %s no longer exists in CCP, removing node pool %s from state", clusterUUID, name)
+			d.SetId("")
+			return nil
+		}
+		return errors.New("UNABLE TO RETRIEVE DETAILS FOR CLUSTER: " + clusterUUID + ": " + err.Error())
+	}
+
+	if cluster.WorkerNodePool != nil {
+		for _, pool := range *cluster.WorkerNodePool {
+			if stringValue(pool.Name) == name {
+				return setNodePoolResourceData(d, clusterUUID, &pool)
+			}
+		}
+	}
+
+	log.Printf("[WARN] Node pool %s no longer exists in cluster %s, removing from state", name, clusterUUID)
+	d.SetId("")
+	return nil
+}
+
+func resourceNodePoolUpdate(d *schema.ResourceData, m interface{}) error {
+
+	client := m.(*ccp.Client)
+
+	deadline := time.Now().Add(d.Timeout(schema.TimeoutUpdate))
+
+	// a change that fails stays out of the state so it is tried again on the next apply
+	d.Partial(true)
+
+	clusterUUID, name, err := parseNodePoolID(d.Id())
+
+	if err != nil {
+		return err
+	}
+
+	if d.HasChange("template") || d.HasChange("kubernetes_version") {
+		workerNodePool := ccp.WorkerNodePool{
+			Template:          ccp.String(d.Get("template").(string)),
+			KubernetesVersion: ccp.String(d.Get("kubernetes_version").(string)),
+		}
+
+		if err := patchNodePool(client, clusterUUID, name, &workerNodePool); err != nil {
+			return errors.New("UNABLE TO UPDATE NODE POOL " + name + ": " + err.Error())
+		}
+
+		if _, err := waitForCluster(client, clusterUUID, time.Until(deadline)); err != nil {
+			return errors.New("ERROR UPGRADING NODE POOL " + name + ": " + err.Error())
+		}
+
+		d.SetPartial("template")
+		d.SetPartial("kubernetes_version")
+	}
+
+	if d.HasChange("size") {
+		if _, err := client.ScaleCluster(clusterUUID, name, d.Get("size").(int)); err != nil {
+			return errors.New("UNABLE TO SCALE NODE POOL " + name + ": " + err.Error())
+		}
+
+		if err := waitForNodePool(client, clusterUUID, name, d.Get("size").(int), time.Until(deadline)); err != nil {
+			return err
+		}
+
+		d.SetPartial("size")
+	}
+
+	d.Partial(false)
+
+	return resourceNodePoolRead(d, m)
+}
+
+func resourceNodePoolDelete(d *schema.ResourceData, m interface{}) error {
+
+	client := m.(*ccp.Client)
+
+	clusterUUID, name, err := parseNodePoolID(d.Id())
+
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+
+	ctx, cancel := context.WithTimeout(context.Background(), d.Timeout(schema.TimeoutDelete))
+	defer cancel()
+
+	err = deleteNodePool(ctx, client, clusterUUID, name)
+
+	if err != nil && !isNotFound(err) {
+		return errors.New(err.Error())
+	}
+
+	// CCP removes the nodes in the background, wait for the pool to go so a pool with the same name can be
+	// added straight afterwards, as happens when a change replaces the pool
+	if err := waitForNodePoolDeleted(client, clusterUUID, name, d.Timeout(schema.TimeoutDelete)-time.Since(start)); err != nil {
+		return err
+	}
+
+	d.SetId("")
+	return nil
+}
+
+func resourceNodePoolCustomizeDiff(d *schema.ResourceDiff, m interface{}) error {
+
+	if d.Id() == "" || !d.HasChange("kubernetes_version") || !d.NewValueKnown("kubernetes_version") {
+		return nil
+	}
+
+	o, n := d.GetChange("kubernetes_version")
+
+	if err := validateKubernetesUpgrade(o.(string), n.(string)); err != nil {
+		return errors.New("kubernetes_version: " + err.Error())
+	}
+
+	return nil
+}
+
+func parseNodePoolID(id string) (string, string, error) {
+
+	parts := strings.SplitN(id, "/", 2)
+
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", errors.New("NODE POOL ID MUST BE IN THE FORMAT <CLUSTER UUID>/<POOL NAME>: " + id)
+	}
+
+	return parts[0], parts[1], nil
+}
+
+func setNodePoolResourceData(d *schema.ResourceData, clusterUUID string, u *ccp.WorkerNodePool) error {
+
+	if err := d.Set("cluster_uuid", clusterUUID); err != nil {
+		return errors.New("CANNOT SET CLUSTER UUID")
+	}
+	if err := d.Set("name", u.Name); err != nil {
+		return errors.New("CANNOT SET NAME")
+	}
+	if err := d.Set("size", u.Size); err != nil {
+		return errors.New("CANNOT SET SIZE")
+	}
+	if err := d.Set("template", u.Template); err != nil {
+		return errors.New("CANNOT SET TEMPLATE")
+	}
+	if err := d.Set("vcpus", u.VCPUs); err != nil {
+		return errors.New("CANNOT SET VCPUS")
+	}
+	if err := d.Set("memory", u.Memory); err != nil {
+		return errors.New("CANNOT SET MEMORY")
+	}
+	if err := d.Set("ssh_user", u.SSHUser); err != nil {
+		return errors.New("CANNOT SET SSH USER")
+	}
+	if err := d.Set("ssh_key", u.SSHKey); err != nil {
+		return errors.New("CANNOT SET SSH KEY")
+	}
+	if err := d.Set("kubernetes_version", u.KubernetesVersion); err != nil {
+		return errors.New("CANNOT SET KUBERNETES VERSION")
+	}
+	if err := d.Set("nodes", flattenNodes(u.Nodes)); err != nil {
+		return errors.New("CANNOT SET NODES")
+	}
+
+	return nil
+}
+
+// flattenNodes creates one entry per node, leaving out any details CCP hasn't reported yet
+func flattenNodes(nodes *[]ccp.Node) []interface{} {
+
+	nodesOut := make([]interface{}, 0)
+
+	if nodes == nil {
+		return nodesOut
+	}
+
+	for _, node := range *nodes {
+		nodesOut = append(nodesOut, map[string]interface{}{
+			"name":          stringValue(node.Name),
+			"status":        stringValue(node.Status),
+			"status_detail": stringValue(node.StatusDetail),
+			"status_reason": stringValue(node.StatusReason),
+			"private_ip":    stringValue(node.PrivateIP),
+			"public_ip":     stringValue(node.PublicIP),
+			"phase":         stringValue(node.Phase),
+		})
+	}
+
+	return nodesOut
+}
@@ -16,6 +16,10 @@ or implied.*/
 package main
 
 import (
+	"errors"
+	"log"
+
+	"github.com/ccp-client-library/ccp"
 	"github.com/hashicorp/terraform/helper/schema"
 )
 
@@ -26,6 +30,11 @@ func resourceUser() *schema.Resource {
 		Update: resourceUserUpdate,
 		Delete: resourceUserDelete,
 
+		// users are identified by their username
+		Importer: &schema.ResourceImporter{
+			State: schema.ImportStatePassthrough,
+		},
+
 		Schema: map[string]*schema.Schema{
 			"username": &schema.Schema{
 				Type:     schema.TypeString,
@@ -36,6 +45,7 @@ func resourceUser() *schema.Resource {
 				Type:      schema.TypeString,
 				Required:  true,
 				Sensitive: true,
+				StateFunc: hashSecret,
 			},
 			"firstname": &schema.Schema{
 				Type:     schema.TypeString,
@@ -59,9 +69,6 @@ func resourceUser() *schema.Resource {
 
 func resourceUserCreate(d *schema.ResourceData, m interface{}) error {
 
-	/*username := d.Get("username").(string)
-	d.SetId(username)
-
 	client := m.(*ccp.Client)
 
 	newUser := ccp.User{
@@ -74,80 +81,84 @@ func resourceUserCreate(d *schema.ResourceData, m interface{}) error {
 		Role:      ccp.String(d.Get("role").(string)),
 	}
 
-	user, err := client.AddUser(&newUser)
+	_, err := client.AddUser(&newUser)
 
 	if err != nil {
 		return errors.New(err.Error())
 	}
 
-	return setUserResourceData(d, user)*/
+	d.SetId(d.Get("username").(string))
 
-	return nil
+	return resourceUserRead(d, m)
 }
 
 func resourceUserRead(d *schema.ResourceData, m interface{}) error {
 
-	/*
-		client := m.(*ccp.Client)
-		user, err := client.GetUser(d.Get("username").(string))
+	client := m.(*ccp.Client)
+
+	user, err := getUser(client, d.Id())
 
-		if err != nil {
-			return errors.New("UNABLE TO RETRIEVE DETAILS FOR USER: " + d.Get("username").(string))
+	if err != nil {
+		if isNotFound(err) {
+			log.Printf("[WARN] User %s no longer exists in CCP, removing from state", d.Id())
+			d.SetId("")
+			return nil
 		}
+		return errors.New("UNABLE TO RETRIEVE DETAILS FOR USER: " + d.Id() + ": " + err.Error())
+	}
 
-		return setUserResourceData(d, user)
-	*/
-
-	return nil
+	return setUserResourceData(d, user)
 }
 
 func resourceUserUpdate(d *schema.ResourceData, m interface{}) error {
 
-	/*client := m.(*ccp.Client)
+	client := m.(*ccp.Client)
 
 	newUser := ccp.User{
-		Username:  ccp.String(d.Get("username").(string)),
+		Username:  ccp.String(d.Id()),
 		FirstName: ccp.String(d.Get("firstname").(string)),
 		LastName:  ccp.String(d.Get("lastname").(string)),
-		Password:  ccp.String(d.Get("password").(string)),
 		Disable:   ccp.Bool(d.Get("disable").(bool)),
 		Role:      ccp.String(d.Get("role").(string)),
 	}
 
-	user, err := client.PatchUser(&newUser)
+	// only send the password when it has been changed so CCP doesn't reset it on every update
+	if d.HasChange("password") {
+		newUser.Password = ccp.String(d.Get("password").(string))
+	}
+
+	_, err := client.PatchUser(&newUser)
 
 	if err != nil {
 		return errors.New(err.Error())
 	}
 
-	user, err = client.GetUser(d.Get("username").(string))
+	user, err := getUser(client, d.Id())
 
 	if err != nil {
-		return errors.New("UNABLE TO RETRIEVE DETAILS FOR USER: " + d.Get("username").(string))
+		return errors.New("UNABLE TO RETRIEVE DETAILS FOR USER: " + d.Id() + ": " + err.Error())
 	}
 
 	return setUserResourceData(d, user)
-	*/
-	return nil
 }
 
 func resourceUserDelete(d *schema.ResourceData, m interface{}) error {
 
-	/*client := m.(*ccp.Client)
+	client := m.(*ccp.Client)
 
-	err := client.DeleteUser(d.Get("username").(string))
+	err := deleteUser(client, d.Id())
 
-	if err != nil {
+	if err != nil && !isNotFound(err) {
 		return errors.New(err.Error())
 	}
 
 	d.SetId("")
 	return nil
-	*/
-	return nil
 }
 
-/*func setUserResourceData(d *schema.ResourceData, u *ccp.User) error {
+func setUserResourceData(d *schema.ResourceData, u *ccp.User) error {
+
+	// CCP never returns the password so the hash in state is left as it is
 
 	if err := d.Set("firstname", u.FirstName); err != nil {
 		return errors.New("CANNOT SET FIRST NAME")
@@ -158,9 +169,6 @@ func resourceUserDelete(d *schema.ResourceData, m interface{}) error {
 	if err := d.Set("username", u.Username); err != nil {
 		return errors.New("CANNOT SET USERNAME")
 	}
-	if err := d.Set("password", u.Password); err != nil {
-		return errors.New("CANNOT SET PASSWORD")
-	}
 	if err := d.Set("disable", u.Disable); err != nil {
 		return errors.New("CANNOT SET DISABLE FIELD")
 	}
@@ -168,4 +176,4 @@ func resourceUserDelete(d *schema.ResourceData, m interface{}) error {
 		return errors.New("CANNOT SET ROLE")
 	}
 	return nil
-}*/
+}
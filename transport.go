@@ -0,0 +1,262 @@
+/*Copyright (c) 2019 Cisco and/or its affiliates.
+
+This software is licensed to you under the terms of the Cisco Sample
+Code License, Version 1.0 (the "License"). You may obtain a copy of the
+License at
+
+https://developer.cisco.com/docs/licenses
+
+All use of the material herein must be in accordance with the terms of
+the License. All rights not expressly granted by the License are
+reserved. Unless required by applicable law or agreed to separately in
+writing, software distributed under the License is distributed on an "AS
+IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+or implied.*/
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ccp-client-library/ccp"
+)
+
+// transport sits underneath the ccp.Client so that requests made by the client library can be
+// replayed with a new session when the one obtained in Config.Client has expired, and so idempotent
+// requests are retried when CCP is briefly unavailable. It is shared by every resource so the login
+// is guarded and only done once no matter how many requests fail at the same time.
+type transport struct {
+	config *Config
+	base   http.RoundTripper
+	jar    http.CookieJar
+
+	mu      sync.Mutex
+	session int
+
+	// login gets a new session into jar, it is only replaced in tests
+	login func() error
+}
+
+func newTransport(c *Config, base http.RoundTripper, jar http.CookieJar) *transport {
+
+	t := &transport{
+		config: c,
+		base:   base,
+		jar:    jar,
+	}
+
+	t.login = t.loginCCP
+
+	return t
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+
+	// the cookies are taken from the jar along with the session number, so a 401 is always for the
+	// session the request was actually sent with
+	t.mu.Lock()
+	session := t.session
+	req = t.withSession(req)
+	t.mu.Unlock()
+
+	body, err := readBody(req)
+
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := t.send(req, body)
+
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	resp.Body.Close()
+
+	log.Printf("[INFO] CCP session expired during %s %s, logging in again", req.Method, req.URL.Path)
+
+	if err := t.relogin(session); err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	retry := t.withSession(req)
+	t.mu.Unlock()
+
+	return t.send(retry, body)
+}
+
+// withSession replaces the cookies on the request with the ones in the shared jar
+func (t *transport) withSession(req *http.Request) *http.Request {
+
+	if t.jar == nil {
+		return req
+	}
+
+	clone := req.Clone(req.Context())
+	clone.Header.Del("Cookie")
+
+	for _, cookie := range t.jar.Cookies(req.URL) {
+		clone.AddCookie(cookie)
+	}
+
+	return clone
+}
+
+// send makes the request, retrying GETs and DELETEs with an exponential backoff when CCP can't be
+// reached or responds with a status that means it should be tried again later
+func (t *transport) send(req *http.Request, body []byte) (*http.Response, error) {
+
+	for attempt := 0; ; attempt++ {
+
+		resp, err := t.base.RoundTrip(withBody(req, body))
+
+		if !isIdempotent(req.Method) || attempt >= t.config.Max_retries || !shouldRetry(resp, err) {
+			return resp, err
+		}
+
+		wait := t.backoff(attempt, resp)
+
+		if err != nil {
+			log.Printf("[WARN] %s %s failed: %s, retrying in %s (%d/%d)", req.Method, req.URL.Path, err, wait, attempt+1, t.config.Max_retries)
+		} else {
+			log.Printf("[WARN] %s %s returned %s, retrying in %s (%d/%d)", req.Method, req.URL.Path, resp.Status, wait, attempt+1, t.config.Max_retries)
+			resp.Body.Close()
+		}
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// backoff doubles the wait for every attempt up to retry_wait_max, adding jitter so parallel
+// requests don't all hit CCP again at the same moment. A Retry-After header from CCP takes precedence.
+func (t *transport) backoff(attempt int, resp *http.Response) time.Duration {
+
+	min := time.Duration(t.config.Retry_wait_min) * time.Second
+	max := time.Duration(t.config.Retry_wait_max) * time.Second
+
+	if resp != nil {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			wait := time.Duration(seconds) * time.Second
+			if wait > max {
+				wait = max
+			}
+			return wait
+		}
+	}
+
+	wait := min << uint(attempt)
+
+	if wait <= 0 || wait > max {
+		wait = max
+	}
+
+	if wait <= min {
+		return wait
+	}
+
+	return min + time.Duration(rand.Int63n(int64(wait-min)))
+}
+
+func isIdempotent(method string) bool {
+
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodDelete:
+		return true
+	}
+
+	return false
+}
+
+func shouldRetry(resp *http.Response, err error) bool {
+
+	if err != nil {
+		return true
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+
+	return false
+}
+
+// relogin gets a new session from CCP unless another request has already done so since the
+// session the caller was using
+func (t *transport) relogin(session int) error {
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.session != session {
+		return nil
+	}
+
+	if err := t.login(); err != nil {
+		return err
+	}
+
+	t.session++
+
+	return nil
+}
+
+// loginCCP logs in with a new client and copies its session cookie into the jar shared by every request
+func (t *transport) loginCCP() error {
+
+	fresh := ccp.NewClient(t.config.Username, t.config.Password, t.config.Base_url)
+	fresh.HTTPClient = newHTTPClient(t.base)
+
+	if err := fresh.Login(fresh); err != nil {
+		return t.config.loginError(err)
+	}
+
+	baseURL, err := url.Parse(t.config.Base_url)
+
+	if err != nil {
+		return err
+	}
+
+	if t.jar != nil && fresh.HTTPClient.Jar != nil {
+		t.jar.SetCookies(baseURL, fresh.HTTPClient.Jar.Cookies(baseURL))
+	}
+
+	return nil
+}
+
+// readBody drains the request body so the request can be sent more than once
+func readBody(req *http.Request) ([]byte, error) {
+
+	if req.Body == nil {
+		return nil, nil
+	}
+
+	defer req.Body.Close()
+
+	return ioutil.ReadAll(req.Body)
+}
+
+func withBody(req *http.Request, body []byte) *http.Request {
+
+	clone := req.Clone(req.Context())
+
+	if body != nil {
+		clone.Body = ioutil.NopCloser(bytes.NewReader(body))
+		clone.ContentLength = int64(len(body))
+	}
+
+	return clone
+}
@@ -0,0 +1,211 @@
+/*Copyright (c) 2019 Cisco and/or its affiliates.
+
+This software is licensed to you under the terms of the Cisco Sample
+Code License, Version 1.0 (the "License"). You may obtain a copy of the
+License at
+
+https://developer.cisco.com/docs/licenses
+
+All use of the material herein must be in accordance with the terms of
+the License. All rights not expressly granted by the License are
+reserved. Unless required by applicable law or agreed to separately in
+writing, software distributed under the License is distributed on an "AS
+IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+or implied.*/
+
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func newTestTransport(t *testing.T, serverURL string) (*transport, *http.Client) {
+
+	jar, err := cookiejar.New(nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := &Config{
+		Base_url:       serverURL,
+		Max_retries:    2,
+		Retry_wait_min: 1,
+		Retry_wait_max: 1,
+	}
+
+	tr := newTransport(config, http.DefaultTransport, jar)
+
+	return tr, &http.Client{Transport: tr, Jar: jar}
+}
+
+func TestTransportLogsInOnceForConcurrentExpiredSessions(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cookie, err := r.Cookie("session"); err != nil || cookie.Value != "valid" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	tr, client := newTestTransport(t, server.URL)
+
+	serverURL, err := url.Parse(server.URL)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var logins int32
+
+	tr.login = func() error {
+		atomic.AddInt32(&logins, 1)
+		tr.jar.SetCookies(serverURL, []*http.Cookie{{Name: "session", Value: "valid", Path: "/"}})
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			resp, err := client.Post(server.URL+"/v3/clusters/", "application/json", strings.NewReader(`{"name":"test"}`))
+
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			resp.Body.Close()
+
+			if resp.StatusCode != http.StatusOK {
+				errs <- &apiError{StatusCode: resp.StatusCode}
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("request failed: %s", err)
+	}
+
+	if n := atomic.LoadInt32(&logins); n != 1 {
+		t.Errorf("expected 1 login, got %d", n)
+	}
+}
+
+func TestTransportReplaysBodyAfterLogin(t *testing.T) {
+
+	var bodies []string
+	var mu sync.Mutex
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+
+		mu.Lock()
+		bodies = append(bodies, string(body))
+		mu.Unlock()
+
+		if _, err := r.Cookie("session"); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	tr, client := newTestTransport(t, server.URL)
+
+	serverURL, _ := url.Parse(server.URL)
+
+	tr.login = func() error {
+		tr.jar.SetCookies(serverURL, []*http.Cookie{{Name: "session", Value: "valid", Path: "/"}})
+		return nil
+	}
+
+	resp, err := client.Post(server.URL+"/v3/clusters/", "application/json", strings.NewReader(`{"name":"test"}`))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected %d, got %d", http.StatusCreated, resp.StatusCode)
+	}
+
+	if len(bodies) != 2 || bodies[0] != bodies[1] || bodies[1] != `{"name":"test"}` {
+		t.Errorf("expected the body to be sent twice unchanged, got %q", bodies)
+	}
+}
+
+func TestTransportRetries(t *testing.T) {
+
+	cases := []struct {
+		method   string
+		requests int32
+	}{
+		// GETs and DELETEs are retried up to max_retries times
+		{http.MethodGet, 3},
+		{http.MethodDelete, 3},
+		// anything that isn't idempotent is only sent once
+		{http.MethodPost, 1},
+		{http.MethodPatch, 1},
+		{http.MethodPut, 1},
+	}
+
+	for _, c := range cases {
+		t.Run(c.method, func(t *testing.T) {
+
+			var requests int32
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&requests, 1)
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}))
+			defer server.Close()
+
+			_, client := newTestTransport(t, server.URL)
+
+			req, err := http.NewRequest(c.method, server.URL+"/v3/clusters/", strings.NewReader("{}"))
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp, err := client.Do(req)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp.Body.Close()
+
+			if resp.StatusCode != http.StatusServiceUnavailable {
+				t.Errorf("expected %d, got %d", http.StatusServiceUnavailable, resp.StatusCode)
+			}
+
+			if n := atomic.LoadInt32(&requests); n != c.requests {
+				t.Errorf("expected %d requests, got %d", c.requests, n)
+			}
+		})
+	}
+}
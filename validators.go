@@ -0,0 +1,218 @@
+/*Copyright (c) 2019 Cisco and/or its affiliates.
+
+This software is licensed to you under the terms of the Cisco Sample
+Code License, Version 1.0 (the "License"). You may obtain a copy of the
+License at
+
+https://developer.cisco.com/docs/licenses
+
+All use of the material herein must be in accordance with the terms of
+the License. All rights not expressly granted by the License are
+reserved. Unless required by applicable law or agreed to separately in
+writing, software distributed under the License is distributed on an "AS
+IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+or implied.*/
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/validation"
+)
+
+var (
+	clusterTypes        = []string{"vsphere", "aws", "azure"}
+	ipAllocationMethods = []string{"ccpnet", "dhcp"}
+	networkPlugins      = []string{"calico", "contiv-aci", "cilium"}
+)
+
+var validateSSHKey = validation.StringMatch(
+	regexp.MustCompile(`^(ssh-(rsa|dss|ed25519)|ecdsa-sha2-nistp(256|384|521)) [A-Za-z0-9+/]+={0,3}( .*)?$`),
+	"must be an OpenSSH public key such as ssh-ed25519 AAAA... or ssh-rsa AAAA...",
+)
+
+// validateCIDR accepts an address in CIDR notation, without requiring it to be the network address,
+// for fields such as docker_bip that are given as the gateway address and prefix
+func validateCIDR(i interface{}, k string) (s []string, es []error) {
+
+	v, ok := i.(string)
+
+	if !ok {
+		es = append(es, fmt.Errorf("expected type of %s to be string", k))
+		return
+	}
+
+	if _, _, err := net.ParseCIDR(v); err != nil {
+		es = append(es, fmt.Errorf("expected %s to be in CIDR notation, got: %s", k, v))
+	}
+
+	return
+}
+
+// validateGatewayCIDR accepts a gateway address and prefix such as 100.65.0.1/16, where the address has
+// to be one of the usable addresses in the subnet rather than the network or broadcast address
+func validateGatewayCIDR(i interface{}, k string) (s []string, es []error) {
+
+	v, ok := i.(string)
+
+	if !ok {
+		es = append(es, fmt.Errorf("expected type of %s to be string", k))
+		return
+	}
+
+	ip, ipnet, err := net.ParseCIDR(v)
+
+	if err != nil || ip.To4() == nil {
+		es = append(es, fmt.Errorf("expected %s to be an IPv4 gateway address in CIDR notation such as 100.65.0.1/16, got: %s", k, v))
+		return
+	}
+
+	broadcast := make(net.IP, len(ipnet.IP))
+	for i := range ipnet.IP {
+		broadcast[i] = ipnet.IP[i] | ^ipnet.Mask[i]
+	}
+
+	if ip.Equal(ipnet.IP) || ip.To4().Equal(broadcast) {
+		es = append(es, fmt.Errorf("expected %s to be a gateway address within %s, got the network or broadcast address: %s", k, ipnet, v))
+	}
+
+	return
+}
+
+var multicastNetwork = &net.IPNet{IP: net.IPv4(224, 0, 0, 0).To4(), Mask: net.CIDRMask(4, 32)}
+
+// validateMulticastCIDR accepts a range in CIDR notation that is within 224.0.0.0/4
+func validateMulticastCIDR(i interface{}, k string) (s []string, es []error) {
+
+	v, ok := i.(string)
+
+	if !ok {
+		es = append(es, fmt.Errorf("expected type of %s to be string", k))
+		return
+	}
+
+	_, ipnet, err := net.ParseCIDR(v)
+
+	if err != nil {
+		es = append(es, fmt.Errorf("expected %s to be in CIDR notation, got: %s", k, v))
+		return
+	}
+
+	if ones, _ := ipnet.Mask.Size(); !multicastNetwork.Contains(ipnet.IP) || ones < 4 {
+		es = append(es, fmt.Errorf("expected %s to be within the multicast range %s, got: %s", k, multicastNetwork, v))
+	}
+
+	return
+}
+
+// cidrsOverlap reports whether the subnets of two addresses in CIDR notation share any addresses
+func cidrsOverlap(a string, b string) bool {
+
+	_, netA, errA := net.ParseCIDR(a)
+	_, netB, errB := net.ParseCIDR(b)
+
+	if errA != nil || errB != nil {
+		return false
+	}
+
+	return netA.Contains(netB.IP) || netB.Contains(netA.IP)
+}
+
+func validateKubernetesVersion(i interface{}, k string) (s []string, es []error) {
+
+	v, ok := i.(string)
+
+	if !ok {
+		es = append(es, fmt.Errorf("expected type of %s to be string", k))
+		return
+	}
+
+	if _, err := parseKubernetesVersion(v); err != nil {
+		es = append(es, fmt.Errorf("expected %s to be a Kubernetes version such as 1.16.3, got: %s", k, v))
+	}
+
+	return
+}
+
+// validateKubernetesUpgrade checks that going from oldVersion to newVersion is an upgrade CCP can do,
+// which is to the same or the next minor version only
+func validateKubernetesUpgrade(oldVersion string, newVersion string) error {
+
+	if oldVersion == "" || oldVersion == newVersion {
+		return nil
+	}
+
+	oldParts, err := parseKubernetesVersion(oldVersion)
+
+	if err != nil {
+		return err
+	}
+
+	newParts, err := parseKubernetesVersion(newVersion)
+
+	if err != nil {
+		return err
+	}
+
+	if newParts[0] != oldParts[0] {
+		return errors.New("CANNOT UPGRADE FROM " + oldVersion + " TO " + newVersion + ", CHANGING THE MAJOR VERSION IS NOT SUPPORTED")
+	}
+
+	if newParts[1] < oldParts[1] || (newParts[1] == oldParts[1] && newParts[2] < oldParts[2]) {
+		return errors.New("CANNOT DOWNGRADE FROM " + oldVersion + " TO " + newVersion)
+	}
+
+	if newParts[1] > oldParts[1]+1 {
+		return errors.New("CANNOT UPGRADE FROM " + oldVersion + " TO " + newVersion + ", UPGRADE TO " +
+			strconv.Itoa(oldParts[0]) + "." + strconv.Itoa(oldParts[1]+1) + " FIRST")
+	}
+
+	return nil
+}
+
+// parseKubernetesVersion splits a version such as 1.16.3 or v1.16 into its major, minor and patch numbers
+func parseKubernetesVersion(version string) ([3]int, error) {
+
+	var parts [3]int
+
+	fields := strings.Split(strings.TrimPrefix(version, "v"), ".")
+
+	if len(fields) < 2 || len(fields) > 3 {
+		return parts, errors.New("INVALID KUBERNETES VERSION: " + version)
+	}
+
+	for i, field := range fields {
+		n, err := strconv.Atoi(field)
+
+		if err != nil || n < 0 {
+			return parts, errors.New("INVALID KUBERNETES VERSION: " + version)
+		}
+
+		parts[i] = n
+	}
+
+	return parts, nil
+}
+
+// hashSecret is used as the StateFunc for write only secrets so that state holds a SHA-256 hash of the
+// secret rather than the secret itself, which is still enough for Terraform to notice it has changed
+func hashSecret(v interface{}) string {
+
+	secret, ok := v.(string)
+
+	if !ok || secret == "" {
+		return ""
+	}
+
+	sum := sha256.Sum256([]byte(secret))
+
+	return hex.EncodeToString(sum[:])
+}
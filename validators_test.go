@@ -0,0 +1,164 @@
+/*Copyright (c) 2019 Cisco and/or its affiliates.
+
+This software is licensed to you under the terms of the Cisco Sample
+Code License, Version 1.0 (the "License"). You may obtain a copy of the
+License at
+
+https://developer.cisco.com/docs/licenses
+
+All use of the material herein must be in accordance with the terms of
+the License. All rights not expressly granted by the License are
+reserved. Unless required by applicable law or agreed to separately in
+writing, software distributed under the License is distributed on an "AS
+IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+or implied.*/
+
+package main
+
+import (
+	"testing"
+)
+
+func TestValidateGatewayCIDR(t *testing.T) {
+
+	cases := []struct {
+		value string
+		valid bool
+	}{
+		{"100.65.0.1/16", true},
+		{"100.65.255.254/16", true},
+		{"10.0.0.5/24", true},
+		// network address
+		{"100.65.0.0/16", false},
+		// broadcast address
+		{"100.65.255.255/16", false},
+		{"10.0.0.255/24", false},
+		// a /32 has no addresses other than the network address
+		{"10.0.0.1/32", false},
+		{"100.65.0.1", false},
+		{"100.65.0.1/33", false},
+		{"fd00::1/64", false},
+		{"", false},
+	}
+
+	for _, c := range cases {
+		_, errs := validateGatewayCIDR(c.value, "pod_subnet_start")
+
+		if valid := len(errs) == 0; valid != c.valid {
+			t.Errorf("validateGatewayCIDR(%q): expected valid to be %t, got errors %v", c.value, c.valid, errs)
+		}
+	}
+}
+
+func TestValidateMulticastCIDR(t *testing.T) {
+
+	cases := []struct {
+		value string
+		valid bool
+	}{
+		{"225.32.0.0/16", true},
+		{"224.0.0.0/4", true},
+		{"239.255.255.255/32", true},
+		{"223.255.0.0/16", false},
+		{"240.0.0.0/8", false},
+		// wider than the multicast range itself
+		{"224.0.0.0/3", false},
+		{"225.32.0.0", false},
+		{"", false},
+	}
+
+	for _, c := range cases {
+		_, errs := validateMulticastCIDR(c.value, "multicast_range")
+
+		if valid := len(errs) == 0; valid != c.valid {
+			t.Errorf("validateMulticastCIDR(%q): expected valid to be %t, got errors %v", c.value, c.valid, errs)
+		}
+	}
+}
+
+func TestCIDRsOverlap(t *testing.T) {
+
+	cases := []struct {
+		a, b    string
+		overlap bool
+	}{
+		{"100.65.0.1/16", "100.66.0.1/16", false},
+		{"100.65.0.1/16", "100.65.128.1/24", true},
+		{"100.65.128.1/24", "100.65.0.1/16", true},
+		{"10.0.0.1/32", "10.0.0.1/32", true},
+		{"10.0.0.1/32", "10.0.0.2/32", false},
+		{"10.0.0.1/8", "not a cidr", false},
+	}
+
+	for _, c := range cases {
+		if overlap := cidrsOverlap(c.a, c.b); overlap != c.overlap {
+			t.Errorf("cidrsOverlap(%q, %q): expected %t, got %t", c.a, c.b, c.overlap, overlap)
+		}
+	}
+}
+
+func TestParseKubernetesVersion(t *testing.T) {
+
+	cases := []struct {
+		value   string
+		version [3]int
+		valid   bool
+	}{
+		{"1.16.3", [3]int{1, 16, 3}, true},
+		{"v1.16.3", [3]int{1, 16, 3}, true},
+		{"1.16", [3]int{1, 16, 0}, true},
+		{"v1.16", [3]int{1, 16, 0}, true},
+		{"1", [3]int{}, false},
+		{"1.16.3.1", [3]int{}, false},
+		{"1.x.3", [3]int{}, false},
+		{"1.-16.3", [3]int{}, false},
+		{"V1.16.3", [3]int{}, false},
+		{"", [3]int{}, false},
+	}
+
+	for _, c := range cases {
+		version, err := parseKubernetesVersion(c.value)
+
+		if valid := err == nil; valid != c.valid {
+			t.Errorf("parseKubernetesVersion(%q): expected valid to be %t, got error %v", c.value, c.valid, err)
+			continue
+		}
+
+		if c.valid && version != c.version {
+			t.Errorf("parseKubernetesVersion(%q): expected %v, got %v", c.value, c.version, version)
+		}
+	}
+}
+
+func TestValidateKubernetesUpgrade(t *testing.T) {
+
+	cases := []struct {
+		from, to string
+		valid    bool
+	}{
+		{"1.16.3", "1.16.3", true},
+		{"1.16.3", "1.16.4", true},
+		{"1.16.3", "1.17.0", true},
+		{"1.16.3", "v1.17.0", true},
+		{"v1.16.3", "1.16.3", true},
+		// nothing to upgrade from when the cluster is being created
+		{"", "1.16.3", true},
+		// skipped minor version
+		{"1.15.7", "1.17.0", false},
+		// downgrades
+		{"1.16.3", "1.16.2", false},
+		{"1.16.3", "1.15.9", false},
+		{"v1.16.3", "v1.15.3", false},
+		// major version changes
+		{"1.16.3", "2.0.0", false},
+		{"1.16.3", "not a version", false},
+	}
+
+	for _, c := range cases {
+		err := validateKubernetesUpgrade(c.from, c.to)
+
+		if valid := err == nil; valid != c.valid {
+			t.Errorf("validateKubernetesUpgrade(%q, %q): expected valid to be %t, got error %v", c.from, c.to, c.valid, err)
+		}
+	}
+}
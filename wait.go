@@ -0,0 +1,268 @@
+/*Copyright (c) 2019 Cisco and/or its affiliates.
+
+This software is licensed to you under the terms of the Cisco Sample
+Code License, Version 1.0 (the "License"). You may obtain a copy of the
+License at
+
+https://developer.cisco.com/docs/licenses
+
+All use of the material herein must be in accordance with the terms of
+the License. All rights not expressly granted by the License are
+reserved. Unless required by applicable law or agreed to separately in
+writing, software distributed under the License is distributed on an "AS
+IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+or implied.*/
+
+package main
+
+import (
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ccp-client-library/ccp"
+	"github.com/hashicorp/terraform/helper/resource"
+)
+
+const (
+	nodePoolResizing = "resizing"
+	nodePoolReady    = "ready"
+	nodePoolDeleting = "deleting"
+
+	clusterPending  = "pending"
+	clusterReady    = "ready"
+	clusterDeleting = "deleting"
+)
+
+// clusterStatus is the status CCP last reported for the cluster, for use in error messages
+func clusterStatus(client *ccp.Client, clusterUUID string) string {
+
+	cluster, err := getCluster(client, clusterUUID)
+
+	if err != nil {
+		if isNotFound(err) {
+			return "NOT FOUND"
+		}
+		return "UNKNOWN (" + err.Error() + ")"
+	}
+
+	return stringValue(cluster.Status)
+}
+
+// waitForNodePool polls the cluster until the named pool has size nodes and every one of them has finished
+// being created, so the node details are in state at the end of the apply rather than after a refresh
+func waitForNodePool(client *ccp.Client, clusterUUID string, name string, size int, timeout time.Duration) error {
+
+	stateConf := &resource.StateChangeConf{
+		Pending:    []string{nodePoolResizing},
+		Target:     []string{nodePoolReady},
+		Refresh:    nodePoolStateRefreshFunc(client, clusterUUID, name, size),
+		Timeout:    timeout,
+		Delay:      10 * time.Second,
+		MinTimeout: 10 * time.Second,
+	}
+
+	if _, err := stateConf.WaitForState(); err != nil {
+		return errors.New("ERROR WAITING FOR NODE POOL " + name + " TO HAVE " + strconv.Itoa(size) + " READY NODES: " + err.Error() +
+			", LAST CLUSTER STATUS: " + clusterStatus(client, clusterUUID))
+	}
+
+	return nil
+}
+
+func nodePoolStateRefreshFunc(client *ccp.Client, clusterUUID string, name string, size int) resource.StateRefreshFunc {
+	return func() (interface{}, string, error) {
+
+		cluster, err := getCluster(client, clusterUUID)
+
+		if err != nil {
+			return nil, "", err
+		}
+
+		if cluster.WorkerNodePool == nil {
+			return nil, "", errors.New("NODE POOL " + name + " NOT FOUND IN CLUSTER " + clusterUUID)
+		}
+
+		for _, pool := range *cluster.WorkerNodePool {
+
+			if stringValue(pool.Name) != name {
+				continue
+			}
+
+			ready := 0
+			total := 0
+
+			if pool.Nodes != nil {
+				for _, node := range *pool.Nodes {
+					total++
+
+					phase := strings.ToLower(stringValue(node.Phase))
+
+					switch phase {
+					case "ready", "running":
+						ready++
+					case "error", "failed":
+						return nil, "", errors.New("NODE " + stringValue(node.Name) + " IS IN PHASE " + stringValue(node.Phase) + ": " + stringValue(node.StatusReason))
+					}
+				}
+			}
+
+			log.Printf("[DEBUG] Node pool %s in cluster %s has %d/%d nodes ready, %d requested", name, clusterUUID, ready, total, size)
+
+			if total == size && ready == size {
+				return cluster, nodePoolReady, nil
+			}
+
+			return cluster, nodePoolResizing, nil
+		}
+
+		return nil, "", errors.New("NODE POOL " + name + " NOT FOUND IN CLUSTER " + clusterUUID)
+	}
+}
+
+// waitForCluster polls the cluster until CCP reports it as ready, failing as soon as it reports an error
+func waitForCluster(client *ccp.Client, clusterUUID string, timeout time.Duration) (*ccp.Cluster, error) {
+
+	stateConf := &resource.StateChangeConf{
+		Pending:    []string{clusterPending},
+		Target:     []string{clusterReady},
+		Refresh:    clusterStateRefreshFunc(client, clusterUUID),
+		Timeout:    timeout,
+		Delay:      30 * time.Second,
+		MinTimeout: 15 * time.Second,
+	}
+
+	result, err := stateConf.WaitForState()
+
+	if err != nil {
+		return nil, errors.New(err.Error() + ", LAST CLUSTER STATUS: " + clusterStatus(client, clusterUUID))
+	}
+
+	return result.(*ccp.Cluster), nil
+}
+
+func clusterStateRefreshFunc(client *ccp.Client, clusterUUID string) resource.StateRefreshFunc {
+
+	start := time.Now()
+
+	return func() (interface{}, string, error) {
+
+		cluster, err := getCluster(client, clusterUUID)
+
+		if err != nil {
+			return nil, "", err
+		}
+
+		status := stringValue(cluster.Status)
+
+		log.Printf("[INFO] Cluster %s is %s (%s elapsed)", clusterUUID, status, time.Since(start).Round(time.Second))
+
+		switch {
+		case strings.EqualFold(status, "READY"):
+			return cluster, clusterReady, nil
+		case isClusterFailed(status):
+			return nil, "", errors.New("CCP REPORTED CLUSTER " + clusterUUID + " AS " + status)
+		}
+
+		return cluster, clusterPending, nil
+	}
+}
+
+// isClusterFailed covers ERROR as well as the *_FAILED statuses CCP uses for individual operations
+func isClusterFailed(status string) bool {
+
+	status = strings.ToUpper(status)
+
+	return strings.Contains(status, "ERROR") || strings.Contains(status, "FAIL")
+}
+
+// waitForClusterDeleted polls the cluster until CCP no longer returns it
+func waitForClusterDeleted(client *ccp.Client, clusterUUID string, timeout time.Duration) error {
+
+	stateConf := &resource.StateChangeConf{
+		Pending:    []string{clusterDeleting},
+		Target:     []string{},
+		Refresh:    clusterDeleteRefreshFunc(client, clusterUUID),
+		Timeout:    timeout,
+		Delay:      10 * time.Second,
+		MinTimeout: 10 * time.Second,
+	}
+
+	if _, err := stateConf.WaitForState(); err != nil {
+		return errors.New(err.Error() + ", LAST CLUSTER STATUS: " + clusterStatus(client, clusterUUID))
+	}
+
+	return nil
+}
+
+func clusterDeleteRefreshFunc(client *ccp.Client, clusterUUID string) resource.StateRefreshFunc {
+	return func() (interface{}, string, error) {
+
+		cluster, err := getCluster(client, clusterUUID)
+
+		if err != nil {
+			if isNotFound(err) {
+				return nil, "", nil
+			}
+			return nil, "", err
+		}
+
+		status := stringValue(cluster.Status)
+
+		log.Printf("[INFO] Waiting for cluster %s to be deleted, status is %s", clusterUUID, status)
+
+		if isClusterFailed(status) {
+			return nil, "", errors.New("CCP REPORTED CLUSTER " + clusterUUID + " AS " + status)
+		}
+
+		return cluster, clusterDeleting, nil
+	}
+}
+
+// waitForNodePoolDeleted polls the cluster until the named pool has gone, so a pool with the same name can be
+// added straight afterwards. The pool has also gone once the cluster itself has.
+func waitForNodePoolDeleted(client *ccp.Client, clusterUUID string, name string, timeout time.Duration) error {
+
+	stateConf := &resource.StateChangeConf{
+		Pending:    []string{nodePoolDeleting},
+		Target:     []string{},
+		Refresh:    nodePoolDeleteRefreshFunc(client, clusterUUID, name),
+		Timeout:    timeout,
+		Delay:      10 * time.Second,
+		MinTimeout: 10 * time.Second,
+	}
+
+	if _, err := stateConf.WaitForState(); err != nil {
+		return errors.New("ERROR WAITING FOR NODE POOL " + name + " TO BE DELETED: " + err.Error() +
+			", LAST CLUSTER STATUS: " + clusterStatus(client, clusterUUID))
+	}
+
+	return nil
+}
+
+func nodePoolDeleteRefreshFunc(client *ccp.Client, clusterUUID string, name string) resource.StateRefreshFunc {
+	return func() (interface{}, string, error) {
+
+		cluster, err := getCluster(client, clusterUUID)
+
+		if err != nil {
+			if isNotFound(err) {
+				return nil, "", nil
+			}
+			return nil, "", err
+		}
+
+		if cluster.WorkerNodePool != nil {
+			for _, pool := range *cluster.WorkerNodePool {
+				if stringValue(pool.Name) == name {
+					log.Printf("[INFO] Waiting for node pool %s in cluster %s to be deleted", name, clusterUUID)
+					return pool, nodePoolDeleting, nil
+				}
+			}
+		}
+
+		return nil, "", nil
+	}
+}